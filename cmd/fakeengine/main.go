@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/fakeengine"
+)
+
+func main() {
+	script := flag.String("script", "", "transcript file with rules (see internal/fakeengine)")
+	name := flag.String("name", "", "engine name reported in the uci handshake")
+	delay := flag.Duration("delay", 0, "pause between default info lines")
+	flag.Parse()
+
+	engine := fakeengine.New()
+	engine.Name = *name
+	engine.SearchDelay = *delay
+
+	if *script != "" {
+		f, err := os.Open(*script)
+		if err != nil {
+			log.Fatal(err)
+		}
+		engine.Rules, err = fakeengine.ParseScript(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := engine.Run(os.Stdin, os.Stdout); err != nil {
+		if errors.Is(err, fakeengine.ErrCrashed) {
+			// Give the pipe a moment to flush, then die like a crashed engine.
+			time.Sleep(10 * time.Millisecond)
+			os.Exit(1)
+		}
+		log.Fatal(err)
+	}
+}
@@ -8,10 +8,18 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	"github.com/aminammar1/stockfish-go-ec2/docs"
+	"github.com/aminammar1/stockfish-go-ec2/internal/adapters/stockfish_fake"
+	"github.com/aminammar1/stockfish-go-ec2/internal/adapters/stockfish_local"
 	"github.com/aminammar1/stockfish-go-ec2/internal/adapters/stockfish_ssh"
+	"github.com/aminammar1/stockfish-go-ec2/internal/admission"
 	"github.com/aminammar1/stockfish-go-ec2/internal/app"
+	"github.com/aminammar1/stockfish-go-ec2/internal/cache"
+	"github.com/aminammar1/stockfish-go-ec2/internal/cluster"
 	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/engine"
 	httpadapter "github.com/aminammar1/stockfish-go-ec2/internal/http"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"github.com/aminammar1/stockfish-go-ec2/internal/resilience"
 )
 
 // @title stockfish-ec2-service API
@@ -21,15 +29,102 @@ import (
 func main() {
 	cfg := config.Load()
 
-	adapter := stockfish_ssh.NewAdapter(cfg)
-	service := app.NewChessService(adapter)
+	var backend ports.EngineBackend
+	var engineCluster *cluster.Cluster
+	maxConcurrent := cfg.EngineMaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = cfg.EngineSlots()
+	}
+	switch cfg.EngineBackend {
+	case "ssh":
+		if len(cfg.EngineHosts) == 0 {
+			adapter := stockfish_ssh.NewAdapter(cfg)
+			defer adapter.Close()
+			backend = adapter
+			break
+		}
+		// Each host gets its own adapter and concurrency limit; the shared
+		// limiter below then spans the whole cluster.
+		var members []cluster.Member
+		total := 0
+		for _, host := range cfg.EngineHosts {
+			hostCfg := cfg.ForHost(host)
+			adapter := stockfish_ssh.NewAdapter(hostCfg)
+			defer adapter.Close()
+			limit := hostCfg.EngineMaxConcurrent
+			if limit <= 0 {
+				limit = hostCfg.EngineSlots()
+			}
+			total += limit
+			members = append(members, cluster.Member{
+				Name:          host.Name,
+				Backend:       admission.New(adapter, admission.NewLimiter(limit, cfg.EngineQueueSize, cfg.EngineQueueTimeout, nil, 0)),
+				Weight:        host.Weight,
+				MaxConcurrent: limit,
+				Tags:          host.Tags,
+			})
+		}
+		engineCluster = cluster.New(members, cfg.ClusterEjectAfter, cfg.ClusterProbeInterval, cfg.SSHTimeout)
+		defer engineCluster.Close()
+		backend = engineCluster
+		if cfg.EngineMaxConcurrent <= 0 {
+			maxConcurrent = total
+		}
+		log.Printf("engine cluster: %d hosts, %d concurrent searches", len(members), total)
+	case "local":
+		adapter := stockfish_local.NewAdapter(cfg)
+		defer adapter.Close()
+		backend = adapter
+	case "fake":
+		adapter, err := stockfish_fake.NewAdapter(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer adapter.Close()
+		backend = adapter
+		log.Printf("demo mode: serving analyses from the fake engine")
+	default:
+		log.Fatalf("unknown ENGINE_BACKEND %q (want ssh, local or fake)", cfg.EngineBackend)
+	}
+	admitted := admission.New(backend, admission.NewLimiter(maxConcurrent, cfg.EngineQueueSize, cfg.EngineQueueTimeout, cfg.EnginePriorityCaps, cfg.EngineStarvationAge))
+	resilient := resilience.New(admitted, resilience.NewBreaker(cfg.BreakerThreshold, cfg.BreakerOpenTimeout),
+		cfg.EngineRetries, cfg.EngineRetryBackoff, cfg.EngineRetryMaxBackoff)
+
+	var analyzer ports.StockfishEnginePort = resilient
+	switch cfg.CacheBackend {
+	case "memory":
+		analyzer = cache.New(resilient, cache.NewLRU(cfg.CacheSize), cfg)
+	case "disk":
+		store, err := cache.NewDisk(cfg.CacheDir, cfg.CacheDiskSize)
+		if err != nil {
+			log.Fatal(err)
+		}
+		analyzer = cache.New(resilient, store, cfg)
+	case "off":
+	default:
+		log.Fatalf("unknown CACHE_BACKEND %q (want memory, disk or off)", cfg.CacheBackend)
+	}
+	service := app.NewChessService(analyzer, func(req ports.AnalyzeRequest) (string, error) {
+		key, err := engine.NewSearchKey(req, cfg)
+		return key.Exact(), err
+	})
+	service.SetReviewTimeout(cfg.ReviewTimeout)
+	service.AddStatus("admission", func() any { return admitted.Stats() })
+	service.AddStatus("resilience", func() any { return resilient.Stats() })
+	if engineCluster != nil {
+		service.AddStatus("cluster", func() any { return engineCluster.Stats() })
+	}
+	sessions := app.NewSessionManager(resilient, cfg.SessionIdleTimeout, cfg.MaxSessions)
+	defer sessions.Close()
+	jobs := app.NewJobManager(service, cfg.JobWorkers, cfg.JobQueueSize, cfg.JobRetention)
+	defer jobs.Close()
 
 	r := gin.New()
 	r.Use(gin.Logger(), gin.Recovery())
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	httpadapter.RegisterRoutes(r, service)
+	httpadapter.RegisterRoutes(r, service, sessions, jobs)
 
 	docs.SwaggerInfo.Title = "stockfish-ec2-service API"
 	docs.SwaggerInfo.Description = "Hexagonal service that proxies Stockfish over SSH."
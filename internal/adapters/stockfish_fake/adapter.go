@@ -0,0 +1,50 @@
+package stockfish_fake
+
+import (
+	"context"
+	"os"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/engine"
+	"github.com/aminammar1/stockfish-go-ec2/internal/fakeengine"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// Adapter serves analyses from the in-process fake engine, for demos and
+// local development without Stockfish.
+type Adapter struct {
+	engine *engine.Engine
+}
+
+func NewAdapter(cfg config.Config) (*Adapter, error) {
+	fake := fakeengine.New()
+	fake.SearchDelay = cfg.FakeEngineDelay
+	if cfg.FakeEngineScript != "" {
+		f, err := os.Open(cfg.FakeEngineScript)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if fake.Rules, err = fakeengine.ParseScript(f); err != nil {
+			return nil, err
+		}
+	}
+	return &Adapter{engine: engine.New(fake.Spawn, cfg)}, nil
+}
+
+func (a *Adapter) Close() error {
+	a.engine.Close()
+	return nil
+}
+
+func (a *Adapter) Health(ctx context.Context) error {
+	return a.engine.Ping(ctx)
+}
+
+func (a *Adapter) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	return a.engine.Analyze(ctx, req)
+}
+
+func (a *Adapter) StartSession(ctx context.Context, req ports.AnalyzeRequest) (ports.EngineSession, error) {
+	return a.engine.StartSession(ctx, req)
+}
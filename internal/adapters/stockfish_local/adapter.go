@@ -0,0 +1,87 @@
+package stockfish_local
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os/exec"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/engine"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// Adapter runs Stockfish as a child process of the service.
+type Adapter struct {
+	cfg    config.Config
+	engine *engine.Engine
+}
+
+func NewAdapter(cfg config.Config) *Adapter {
+	a := &Adapter{cfg: cfg}
+	a.engine = engine.New(a.spawn, cfg)
+	return a
+}
+
+func (a *Adapter) Close() error {
+	a.engine.Close()
+	return nil
+}
+
+func (a *Adapter) Health(ctx context.Context) error {
+	if _, err := exec.LookPath(a.cfg.StockfishPath); err != nil {
+		return err
+	}
+	return a.engine.Ping(ctx)
+}
+
+func (a *Adapter) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	return a.engine.Analyze(ctx, req)
+}
+
+func (a *Adapter) StartSession(ctx context.Context, req ports.AnalyzeRequest) (ports.EngineSession, error) {
+	return a.engine.StartSession(ctx, req)
+}
+
+type processConn struct {
+	io.Reader
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (a *Adapter) spawn(ctx context.Context) (io.ReadWriteCloser, error) {
+	// The process outlives the request, so it is not bound to ctx.
+	cmd := exec.Command(a.cfg.StockfishPath)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		// A missing or non-executable binary stays that way until someone
+		// fixes STOCKFISH_PATH.
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+			return nil, &ports.PermanentError{Err: err}
+		}
+		return nil, err
+	}
+	return &processConn{Reader: stdout, WriteCloser: stdin, cmd: cmd}, nil
+}
+
+func (c *processConn) Close() error {
+	c.WriteCloser.Close()
+	done := make(chan error, 1)
+	go func() { done <- c.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(2 * time.Second):
+		c.cmd.Process.Kill()
+		return <-done
+	}
+}
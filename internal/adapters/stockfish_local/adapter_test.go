@@ -0,0 +1,64 @@
+package stockfish_local
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/fakeengine"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// TestMain lets the test binary double as the engine executable: with
+// FAKE_ENGINE set it speaks UCI on stdin/stdout instead of running tests.
+func TestMain(m *testing.M) {
+	if os.Getenv("FAKE_ENGINE") == "1" {
+		if err := fakeengine.New().Run(os.Stdin, os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestAdapter_Analyze(t *testing.T) {
+	t.Setenv("FAKE_ENGINE", "1")
+	adapter := NewAdapter(config.Config{
+		StockfishPath:      os.Args[0],
+		EnginePoolSize:     1,
+		EngineReadyTimeout: 5 * time.Second,
+		AnalysisDepth:      2,
+	})
+	defer adapter.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := adapter.Health(ctx); err != nil {
+		t.Fatalf("Health() error = %v", err)
+	}
+	result, err := adapter.Analyze(ctx, ports.AnalyzeRequest{SANMoves: "e4 e5 Nf3"})
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if result.BestMoveUCI == "" || result.BestMoveSAN == "" || result.Depth != 2 {
+		t.Errorf("Analyze() = %+v", result)
+	}
+}
+
+func TestAdapter_MissingBinaryIsPermanent(t *testing.T) {
+	adapter := NewAdapter(config.Config{
+		StockfishPath:      "/nonexistent/stockfish",
+		EnginePoolSize:     1,
+		EngineReadyTimeout: time.Second,
+	})
+	defer adapter.Close()
+
+	_, err := adapter.Analyze(context.Background(), ports.AnalyzeRequest{UCIMoves: "e2e4"})
+	if !errors.Is(err, ports.ErrPermanent) || !errors.Is(err, ports.ErrUnavailable) {
+		t.Errorf("err = %v, want a permanent unavailable error", err)
+	}
+}
@@ -2,22 +2,35 @@ package stockfish_ssh
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aminammar1/stockfish-go-ec2/internal/config"
 	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"github.com/aminammar1/stockfish-go-ec2/internal/resilience"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 func TestHealth(t *testing.T) {
 	cfg := config.Config{
-		SSHHost:       os.Getenv("SSH_HOST"),
-		SSHUser:       os.Getenv("SSH_USER"),
-		SSHPrivateKey: os.Getenv("SSH_PRIVATE_KEY"),
-		SSHPort:       22,
-		SSHTimeout:    10 * time.Second,
-		StockfishPath: os.Getenv("STOCKFISH_PATH"),
+		SSHHost:        os.Getenv("SSH_HOST"),
+		SSHUser:        os.Getenv("SSH_USER"),
+		SSHPrivateKey:  os.Getenv("SSH_PRIVATE_KEY"),
+		SSHKnownHosts:  os.Getenv("SSH_KNOWN_HOSTS"),
+		SSHHostKeyMode: os.Getenv("SSH_HOST_KEY_MODE"),
+		SSHPort:        22,
+		SSHTimeout:     10 * time.Second,
+		StockfishPath:  os.Getenv("STOCKFISH_PATH"),
 	}
 
 	if cfg.SSHHost == "" {
@@ -34,57 +47,156 @@ func TestHealth(t *testing.T) {
 	}
 }
 
-func TestBuildPositionCommand_FEN(t *testing.T) {
-	tests := []struct {
-		name    string
-		fen     string
-		wantErr bool
-	}{
-		{"starting position", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", false},
-		{"after e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", false},
-		{"invalid fen", "invalid", true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			req := ports.AnalyzeRequest{FEN: tt.fen}
-			_, _, err := buildPositionCommand(req)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("buildPositionCommand() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
+func newTestAdapter(t *testing.T, cfg config.Config) *Adapter {
+	t.Helper()
+	adapter := NewAdapter(cfg)
+	t.Cleanup(func() { adapter.Close() })
+	return adapter
+}
+
+func analyzeStart(t *testing.T, adapter *Adapter) ports.AnalyzeResult {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := adapter.Analyze(ctx, ports.AnalyzeRequest{FEN: startFEN})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if res.BestMoveUCI == "" {
+		t.Fatal("expected a best move")
 	}
+	return res
 }
 
-func TestComputeEvalBar(t *testing.T) {
-	tests := []struct {
-		name    string
-		cp      *int
-		mate    *int
-		wantMin int
-		wantMax int
-	}{
-		{"equal position", intPtr(0), nil, 48, 52},
-		{"white advantage +100cp", intPtr(100), nil, 55, 65},
-		{"black advantage -100cp", intPtr(-100), nil, 35, 45},
-		{"mate for white", nil, intPtr(5), 100, 100},
-		{"mate for black", nil, intPtr(-5), 0, 0},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			bar := computeEvalBar(tt.cp, tt.mate)
-			if bar == nil {
-				t.Error("computeEvalBar() returned nil")
-				return
-			}
-			if *bar < tt.wantMin || *bar > tt.wantMax {
-				t.Errorf("computeEvalBar() = %d, want between %d and %d", *bar, tt.wantMin, tt.wantMax)
-			}
-		})
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+func TestAdapter_PasswordAuth(t *testing.T) {
+	srv := newTestSSHServer(t, nil)
+	adapter := newTestAdapter(t, srv.adapterConfig())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := adapter.Health(ctx); err != nil {
+		t.Fatalf("Health: %v", err)
 	}
+	analyzeStart(t, adapter)
 }
 
-func intPtr(i int) *int {
-	return &i
+func TestAdapter_PublicKeyAuth(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newTestSSHServer(t, signer.PublicKey())
+	cfg := srv.adapterConfig()
+	cfg.SSHPassword = ""
+	cfg.SSHPrivateKey = keyPath
+	analyzeStart(t, newTestAdapter(t, cfg))
+}
+
+func TestAdapter_WrongPassword(t *testing.T) {
+	srv := newTestSSHServer(t, nil)
+	cfg := srv.adapterConfig()
+	cfg.SSHPassword = "wrong"
+	adapter := newTestAdapter(t, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := adapter.Health(ctx); err == nil {
+		t.Fatal("expected an authentication error")
+	}
+}
+
+func TestAdapter_HostKeyTOFU(t *testing.T) {
+	srv := newTestSSHServer(t, nil)
+	cfg := srv.adapterConfig()
+	cfg.SSHHostKeyFingerprints = nil
+	cfg.SSHHostKeyMode = HostKeyModeTOFU
+	cfg.SSHKnownHosts = filepath.Join(t.TempDir(), "known_hosts")
+	analyzeStart(t, newTestAdapter(t, cfg))
+
+	data, err := os.ReadFile(cfg.SSHKnownHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(srv.hostKey.PublicKey())))
+	if !strings.Contains(string(data), authorized) {
+		t.Errorf("known_hosts does not record the server key: %q", data)
+	}
+}
+
+func TestAdapter_HostKeyChanged(t *testing.T) {
+	trusted := newTestSSHServer(t, nil)
+	srv := newTestSSHServer(t, nil)
+	cfg := srv.adapterConfig()
+	cfg.SSHHostKeyFingerprints = nil
+	cfg.SSHHostKeyMode = HostKeyModeTOFU
+	cfg.SSHKnownHosts = filepath.Join(t.TempDir(), "known_hosts")
+
+	// Record a different key for this address, as if the server was replaced.
+	addr := net.JoinHostPort(cfg.SSHHost, strconv.Itoa(cfg.SSHPort))
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, trusted.hostKey.PublicKey())
+	if err := os.WriteFile(cfg.SSHKnownHosts, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := newTestAdapter(t, cfg).Health(ctx)
+	var changed *HostKeyChangedError
+	if !errors.As(err, &changed) {
+		t.Fatalf("expected HostKeyChangedError, got %v", err)
+	}
+
+	// Retrying cannot make the key match, so the search is tried once.
+	before := srv.connections()
+	resilient := resilience.New(newTestAdapter(t, cfg), resilience.NewBreaker(5, time.Minute), 3, time.Millisecond, time.Millisecond)
+	if _, err := resilient.Analyze(ctx, ports.AnalyzeRequest{FEN: startFEN}); !errors.As(err, &changed) || !errors.Is(err, ports.ErrPermanent) {
+		t.Fatalf("Analyze: expected a permanent HostKeyChangedError, got %v", err)
+	}
+	if n := srv.connections() - before; n != 1 {
+		t.Errorf("host key change was attempted %d times, want 1", n)
+	}
+}
+
+func TestAdapter_UnknownEnginePath(t *testing.T) {
+	srv := newTestSSHServer(t, nil)
+	cfg := srv.adapterConfig()
+	cfg.StockfishPath = "/nonexistent/stockfish"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resilient := resilience.New(newTestAdapter(t, cfg), resilience.NewBreaker(5, time.Minute), 3, time.Millisecond, time.Millisecond)
+	if _, err := resilient.Analyze(ctx, ports.AnalyzeRequest{FEN: startFEN}); !errors.Is(err, ports.ErrPermanent) {
+		t.Fatalf("expected a permanent error for a missing engine binary, got %v", err)
+	}
+	if execs, _ := srv.stats(); execs != 1 {
+		t.Errorf("missing engine was started %d times, want 1", execs)
+	}
+}
+
+func TestAdapter_ReconnectAfterDrop(t *testing.T) {
+	srv := newTestSSHServer(t, nil)
+	adapter := newTestAdapter(t, srv.adapterConfig())
+	analyzeStart(t, adapter)
+
+	srv.dropConnections()
+	analyzeStart(t, adapter)
+
+	if execs, _ := srv.stats(); execs < 2 {
+		t.Errorf("expected the engine to be restarted after the drop, got %d execs", execs)
+	}
 }
@@ -0,0 +1,156 @@
+package stockfish_ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	HostKeyModeStrict   = "strict"
+	HostKeyModeTOFU     = "tofu"
+	HostKeyModeInsecure = "insecure"
+)
+
+// HostKeyChangedError reports a server key that differs from the one we
+// trusted before. It is never retried or auto-accepted.
+type HostKeyChangedError struct {
+	Host        string
+	Fingerprint string
+	Expected    []string
+}
+
+func (e *HostKeyChangedError) Is(target error) bool {
+	return target == ports.ErrPermanent
+}
+
+func (e *HostKeyChangedError) Error() string {
+	return fmt.Sprintf("ssh: host key for %s changed (got %s, expected %s); possible man-in-the-middle, update the trusted keys only if the change is legitimate",
+		e.Host, e.Fingerprint, strings.Join(e.Expected, ", "))
+}
+
+// UnknownHostKeyError reports a server whose key is not trusted yet.
+type UnknownHostKeyError struct {
+	Host        string
+	Fingerprint string
+}
+
+func (e *UnknownHostKeyError) Is(target error) bool {
+	return target == ports.ErrPermanent
+}
+
+func (e *UnknownHostKeyError) Error() string {
+	return fmt.Sprintf("ssh: unknown host key %s for %s; add it to SSH_KNOWN_HOSTS or SSH_HOST_KEY_FINGERPRINTS, or use SSH_HOST_KEY_MODE=tofu",
+		e.Fingerprint, e.Host)
+}
+
+// hostKeyVerifier builds the HostKeyCallback for dial from the configured
+// pins, known_hosts file and mode.
+type hostKeyVerifier struct {
+	mode       string
+	knownHosts string
+	pins       []string
+	mu         sync.Mutex
+}
+
+func newHostKeyVerifier(cfg config.Config) *hostKeyVerifier {
+	mode := cfg.SSHHostKeyMode
+	if mode == "" {
+		mode = HostKeyModeStrict
+	}
+	return &hostKeyVerifier{mode: mode, knownHosts: cfg.SSHKnownHosts, pins: cfg.SSHHostKeyFingerprints}
+}
+
+func (v *hostKeyVerifier) callback() (ssh.HostKeyCallback, error) {
+	switch v.mode {
+	case HostKeyModeInsecure:
+		return ssh.InsecureIgnoreHostKey(), nil
+	case HostKeyModeStrict, HostKeyModeTOFU:
+	default:
+		return nil, fmt.Errorf("unknown SSH_HOST_KEY_MODE %q (want strict, tofu or insecure)", v.mode)
+	}
+
+	if len(v.pins) > 0 {
+		return v.checkPinned, nil
+	}
+	if v.knownHosts == "" {
+		return nil, errors.New("host key verification requires SSH_KNOWN_HOSTS or SSH_HOST_KEY_FINGERPRINTS (or SSH_HOST_KEY_MODE=insecure)")
+	}
+	if v.mode == HostKeyModeTOFU {
+		if err := ensureFile(v.knownHosts); err != nil {
+			return nil, err
+		}
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return v.checkKnownHosts(hostname, remote, key)
+	}, nil
+}
+
+func (v *hostKeyVerifier) checkPinned(hostname string, _ net.Addr, key ssh.PublicKey) error {
+	sha := ssh.FingerprintSHA256(key)
+	md5 := ssh.FingerprintLegacyMD5(key)
+	for _, pin := range v.pins {
+		if pin == sha || strings.TrimPrefix(pin, "MD5:") == md5 {
+			return nil
+		}
+	}
+	return &HostKeyChangedError{Host: hostname, Fingerprint: sha, Expected: v.pins}
+}
+
+func (v *hostKeyVerifier) checkKnownHosts(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	check, err := knownhosts.New(v.knownHosts)
+	if err != nil {
+		return err
+	}
+	err = check(hostname, remote, key)
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+	fingerprint := ssh.FingerprintSHA256(key)
+	if len(keyErr.Want) > 0 {
+		expected := make([]string, 0, len(keyErr.Want))
+		for _, k := range keyErr.Want {
+			expected = append(expected, fmt.Sprintf("%s (%s:%d)", ssh.FingerprintSHA256(k.Key), k.Filename, k.Line))
+		}
+		return &HostKeyChangedError{Host: hostname, Fingerprint: fingerprint, Expected: expected}
+	}
+	if v.mode != HostKeyModeTOFU {
+		return &UnknownHostKeyError{Host: hostname, Fingerprint: fingerprint}
+	}
+	return appendKnownHost(v.knownHosts, hostname, key)
+}
+
+func ensureFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func appendKnownHost(path, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return err
+}
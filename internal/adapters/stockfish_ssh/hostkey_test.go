@@ -0,0 +1,90 @@
+package stockfish_ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestHostKeyVerifier_TOFU(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	v := newHostKeyVerifier(config.Config{SSHHostKeyMode: HostKeyModeTOFU, SSHKnownHosts: path})
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+	first, second := newTestHostKey(t), newTestHostKey(t)
+
+	cb, err := v.callback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cb("ec2.example:22", remote, first); err != nil {
+		t.Fatalf("first connection should be trusted: %v", err)
+	}
+	if err := cb("ec2.example:22", remote, first); err != nil {
+		t.Fatalf("persisted key should be accepted: %v", err)
+	}
+
+	var changed *HostKeyChangedError
+	if err := cb("ec2.example:22", remote, second); !errors.As(err, &changed) {
+		t.Fatalf("changed key: got %v, want HostKeyChangedError", err)
+	}
+}
+
+func TestHostKeyVerifier_Strict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	key := newTestHostKey(t)
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+
+	if _, err := newHostKeyVerifier(config.Config{}).callback(); err == nil {
+		t.Error("strict mode without known_hosts or pins should fail")
+	}
+
+	if err := appendKnownHost(path, "ec2.example:22", key); err != nil {
+		t.Fatal(err)
+	}
+	cb, err := newHostKeyVerifier(config.Config{SSHKnownHosts: path}).callback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cb("ec2.example:22", remote, key); err != nil {
+		t.Errorf("known key rejected: %v", err)
+	}
+	var unknown *UnknownHostKeyError
+	if err := cb("other.example:22", remote, key); !errors.As(err, &unknown) {
+		t.Errorf("unknown host: got %v, want UnknownHostKeyError", err)
+	}
+}
+
+func TestHostKeyVerifier_Pinned(t *testing.T) {
+	key, other := newTestHostKey(t), newTestHostKey(t)
+	v := newHostKeyVerifier(config.Config{SSHHostKeyFingerprints: []string{ssh.FingerprintSHA256(key)}})
+	cb, err := v.callback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cb("ec2.example:22", nil, key); err != nil {
+		t.Errorf("pinned key rejected: %v", err)
+	}
+	var changed *HostKeyChangedError
+	if err := cb("ec2.example:22", nil, other); !errors.As(err, &changed) {
+		t.Errorf("unpinned key: got %v, want HostKeyChangedError", err)
+	}
+}
@@ -0,0 +1,232 @@
+package stockfish_ssh
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var errPoolClosed = errors.New("ssh pool closed")
+
+// clientPool keeps a small set of SSH connections open and multiplexes
+// sessions over them, so analyses do not pay for a handshake each time.
+type clientPool struct {
+	dial        func(ctx context.Context) (*ssh.Client, error)
+	size        int
+	idleTimeout time.Duration
+	keepAlive   time.Duration
+
+	mu      sync.Mutex
+	clients []*pooledClient
+	dialing int
+	closed  bool
+	done    chan struct{}
+}
+
+type pooledClient struct {
+	*ssh.Client
+	active   int
+	lastUsed time.Time
+	dead     bool
+}
+
+func newClientPool(dial func(ctx context.Context) (*ssh.Client, error), size int, idleTimeout, keepAlive time.Duration) *clientPool {
+	if size <= 0 {
+		size = 1
+	}
+	p := &clientPool{
+		dial:        dial,
+		size:        size,
+		idleTimeout: idleTimeout,
+		keepAlive:   keepAlive,
+		done:        make(chan struct{}),
+	}
+	if idleTimeout > 0 {
+		go p.reapIdle()
+	}
+	return p
+}
+
+// acquire returns the least busy live connection, dialing a new one while
+// the pool is below its size and every existing connection is in use.
+func (p *clientPool) acquire(ctx context.Context) (*pooledClient, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errPoolClosed
+	}
+	p.pruneLocked()
+
+	var best *pooledClient
+	for _, c := range p.clients {
+		if best == nil || c.active < best.active {
+			best = c
+		}
+	}
+	if best != nil && (best.active == 0 || len(p.clients)+p.dialing >= p.size) {
+		best.active++
+		p.mu.Unlock()
+		return best, nil
+	}
+	p.dialing++
+	p.mu.Unlock()
+
+	client, err := p.dial(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dialing--
+	if err != nil {
+		return nil, err
+	}
+	if p.closed {
+		client.Close()
+		return nil, errPoolClosed
+	}
+	pc := &pooledClient{Client: client, active: 1, lastUsed: time.Now()}
+	p.clients = append(p.clients, pc)
+	go p.watch(pc)
+	return pc, nil
+}
+
+// release hands a connection back. Broken connections are closed and
+// dropped so the next acquire dials a fresh one.
+func (p *clientPool) release(pc *pooledClient, broken bool) {
+	p.mu.Lock()
+	pc.active--
+	pc.lastUsed = time.Now()
+	if broken {
+		p.markDeadLocked(pc)
+	}
+	p.mu.Unlock()
+}
+
+// session opens a new SSH session, reconnecting once if the pooled
+// connection turns out to have been dropped by the remote side.
+func (p *clientPool) session(ctx context.Context) (*pooledClient, *ssh.Session, error) {
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		pc, err := p.acquire(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		session, err := pc.NewSession()
+		if err == nil {
+			return pc, session, nil
+		}
+		p.release(pc, true)
+		lastErr = err
+	}
+	return nil, nil, lastErr
+}
+
+// ping sends an OpenSSH keepalive request over the connection and waits
+// for the reply until ctx is done, since a half-open connection never
+// answers.
+func (p *clientPool) ping(ctx context.Context, pc *pooledClient) error {
+	errc := make(chan error, 1)
+	go func() {
+		_, _, err := pc.SendRequest("keepalive@openssh.com", true, nil)
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *clientPool) watch(pc *pooledClient) {
+	closed := make(chan struct{})
+	go func() {
+		pc.Wait()
+		close(closed)
+	}()
+
+	var tick <-chan time.Time
+	if p.keepAlive > 0 {
+		ticker := time.NewTicker(p.keepAlive)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-closed:
+			p.mu.Lock()
+			p.markDeadLocked(pc)
+			p.mu.Unlock()
+			return
+		case <-tick:
+			ctx, cancel := context.WithTimeout(context.Background(), p.keepAlive)
+			err := p.ping(ctx, pc)
+			cancel()
+			if err != nil {
+				p.mu.Lock()
+				p.markDeadLocked(pc)
+				p.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+func (p *clientPool) reapIdle() {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case now := <-ticker.C:
+			p.mu.Lock()
+			for _, c := range p.clients {
+				if c.active == 0 && now.Sub(c.lastUsed) >= p.idleTimeout {
+					p.markDeadLocked(c)
+				}
+			}
+			p.pruneLocked()
+			p.mu.Unlock()
+		}
+	}
+}
+
+func (p *clientPool) markDeadLocked(pc *pooledClient) {
+	if pc.dead {
+		return
+	}
+	pc.dead = true
+	pc.Close()
+}
+
+func (p *clientPool) pruneLocked() {
+	live := p.clients[:0]
+	for _, c := range p.clients {
+		if !c.dead {
+			live = append(live, c)
+		}
+	}
+	for i := len(live); i < len(p.clients); i++ {
+		p.clients[i] = nil
+	}
+	p.clients = live
+}
+
+func (p *clientPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	for _, c := range p.clients {
+		p.markDeadLocked(c)
+	}
+	p.clients = nil
+	return nil
+}
@@ -0,0 +1,163 @@
+package stockfish_ssh
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestPool returns a pool of connections to s and a count of its dials.
+func newTestPool(t *testing.T, s *testSSHServer, size int, idleTimeout, keepAlive time.Duration) (*clientPool, *atomic.Int32) {
+	t.Helper()
+	var dials atomic.Int32
+	cfg := &ssh.ClientConfig{
+		User:            testUser,
+		Auth:            []ssh.AuthMethod{ssh.Password(testPassword)},
+		HostKeyCallback: ssh.FixedHostKey(s.hostKey.PublicKey()),
+		Timeout:         5 * time.Second,
+	}
+	dial := func(ctx context.Context) (*ssh.Client, error) {
+		dials.Add(1)
+		return ssh.Dial("tcp", s.listener.Addr().String(), cfg)
+	}
+	p := newClientPool(dial, size, idleTimeout, keepAlive)
+	t.Cleanup(func() { p.Close() })
+	return p, &dials
+}
+
+func acquire(t *testing.T, p *clientPool) *pooledClient {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pc, err := p.acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pc
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (p *clientPool) isDead(pc *pooledClient) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return pc.dead
+}
+
+func (p *clientPool) live() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pruneLocked()
+	return len(p.clients)
+}
+
+func TestClientPool_ReusesLeastBusy(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	p, dials := newTestPool(t, s, 2, 0, 0)
+
+	first := acquire(t, p)
+	p.release(first, false)
+	if again := acquire(t, p); again != first || dials.Load() != 1 {
+		t.Fatalf("idle connection was not reused: dials = %d", dials.Load())
+	}
+
+	// The only connection is busy and the pool has room: dial another.
+	second := acquire(t, p)
+	if second == first || dials.Load() != 2 {
+		t.Fatalf("busy connection was shared below the pool size: dials = %d", dials.Load())
+	}
+
+	// At its size the pool shares, always picking the least busy.
+	third := acquire(t, p)
+	fourth := acquire(t, p)
+	if third == fourth || dials.Load() != 2 {
+		t.Errorf("sessions were not spread over the connections: dials = %d", dials.Load())
+	}
+	if s.connections() != 2 {
+		t.Errorf("server saw %d connections, want 2", s.connections())
+	}
+}
+
+func TestClientPool_ReapsIdle(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	p, dials := newTestPool(t, s, 2, 40*time.Millisecond, 0)
+
+	busy := acquire(t, p)
+	idle := acquire(t, p)
+	p.release(idle, false)
+
+	waitFor(t, "the idle connection to be reaped", func() bool { return p.isDead(idle) })
+	if p.isDead(busy) || p.live() != 1 {
+		t.Errorf("busy connection was reaped too")
+	}
+
+	// The busy connection is not shared while the reaped one can be
+	// replaced.
+	if pc := acquire(t, p); pc == idle || pc == busy || dials.Load() != 3 {
+		t.Errorf("acquire after reaping: dials = %d, want 3", dials.Load())
+	}
+}
+
+func TestClientPool_KeepaliveFailure(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	p, dials := newTestPool(t, s, 1, 0, 20*time.Millisecond)
+
+	pc := acquire(t, p)
+	p.release(pc, false)
+	time.Sleep(60 * time.Millisecond)
+	if p.isDead(pc) {
+		t.Fatal("answered keepalives should keep the connection")
+	}
+
+	s.mu.Lock()
+	s.silent = true
+	s.mu.Unlock()
+	waitFor(t, "the silent connection to be dropped", func() bool { return p.isDead(pc) })
+
+	if next := acquire(t, p); next == pc || dials.Load() != 2 {
+		t.Errorf("acquire after keepalive failure: dials = %d, want 2", dials.Load())
+	}
+}
+
+func TestClientPool_SessionReconnectsOnce(t *testing.T) {
+	s := newTestSSHServer(t, nil)
+	p, dials := newTestPool(t, s, 1, 0, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.mu.Lock()
+	s.rejectSessions = 1
+	s.mu.Unlock()
+	pc, session, err := p.session(ctx)
+	if err != nil {
+		t.Fatalf("session after one refusal: %v", err)
+	}
+	session.Close()
+	p.release(pc, false)
+	if dials.Load() != 2 {
+		t.Errorf("dials = %d, want a reconnect", dials.Load())
+	}
+
+	s.mu.Lock()
+	s.rejectSessions = 3
+	s.mu.Unlock()
+	if _, _, err := p.session(ctx); err == nil {
+		t.Fatal("session should fail when every attempt is refused")
+	}
+	if dials.Load() != 3 {
+		t.Errorf("dials = %d, want exactly one more reconnect", dials.Load())
+	}
+}
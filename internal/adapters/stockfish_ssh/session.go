@@ -0,0 +1,70 @@
+package stockfish_ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"golang.org/x/crypto/ssh"
+)
+
+// exitWait bounds how long Close waits for the remote exit status.
+const exitWait = time.Second
+
+// sessionConn is a Stockfish process running in an SSH session on a
+// pooled connection.
+type sessionConn struct {
+	io.Reader
+	io.WriteCloser
+	session *ssh.Session
+	path    string
+	client  *pooledClient
+	pool    *clientPool
+}
+
+func (a *Adapter) spawn(ctx context.Context) (io.ReadWriteCloser, error) {
+	client, session, err := a.pool.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(err error) (io.ReadWriteCloser, error) {
+		session.Close()
+		a.pool.release(client, false)
+		return nil, err
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return fail(err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return fail(err)
+	}
+	if err := session.Start(a.cfg.StockfishPath); err != nil {
+		return fail(err)
+	}
+	return &sessionConn{Reader: stdout, WriteCloser: stdin, session: session, path: a.cfg.StockfishPath, client: client, pool: a.pool}, nil
+}
+
+// Close ends the session. An engine the remote shell could not run, exit
+// status 126 or 127, is reported as a permanent failure.
+func (c *sessionConn) Close() error {
+	c.WriteCloser.Close()
+	exited := make(chan error, 1)
+	go func() { exited <- c.session.Wait() }()
+	err := c.session.Close()
+	select {
+	case waitErr := <-exited:
+		var exit *ssh.ExitError
+		if errors.As(waitErr, &exit) && (exit.ExitStatus() == 126 || exit.ExitStatus() == 127) {
+			err = &ports.PermanentError{Err: fmt.Errorf("engine %q cannot be run on the host: %w", c.path, waitErr)}
+		}
+	case <-time.After(exitWait):
+	}
+	c.pool.release(c.client, false)
+	return err
+}
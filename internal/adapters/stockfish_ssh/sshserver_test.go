@@ -0,0 +1,219 @@
+package stockfish_ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/fakeengine"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	testUser       = "stockfish"
+	testPassword   = "hunter2"
+	testEnginePath = "/usr/local/bin/stockfish"
+)
+
+// testSSHServer is an in-process SSH server whose exec requests for
+// testEnginePath run the fake UCI engine over the session's stdio.
+type testSSHServer struct {
+	t        *testing.T
+	listener net.Listener
+	config   *ssh.ServerConfig
+	hostKey  ssh.Signer
+	engine   *fakeengine.Engine
+
+	mu       sync.Mutex
+	conns    []net.Conn
+	accepted int
+	execs    int
+	sessions int
+	// silent leaves keepalives unanswered, like a half-open connection.
+	silent bool
+	// rejectSessions is how many session channels to refuse next.
+	rejectSessions int
+}
+
+func newTestSSHServer(t *testing.T, authorized ssh.PublicKey) *testSSHServer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testSSHServer{t: t, hostKey: hostKey, engine: fakeengine.New()}
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == testUser && string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, errAuth
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorized != nil && c.User() == testUser && string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errAuth
+		},
+	}
+	s.config.AddHostKey(hostKey)
+
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.close)
+	go s.serve()
+	return s
+}
+
+var errAuth = errors.New("access denied")
+
+func (s *testSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.accepted++
+		s.mu.Unlock()
+		go s.handleConn(conn)
+	}
+}
+
+func (s *testSSHServer) handleConn(conn net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	// Keepalives get a "false" reply, which is all the client checks for.
+	go func() {
+		for req := range reqs {
+			s.mu.Lock()
+			silent := s.silent
+			s.mu.Unlock()
+			if req.WantReply && !silent {
+				req.Reply(false, nil)
+			}
+		}
+	}()
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		s.mu.Lock()
+		reject := s.rejectSessions > 0
+		if reject {
+			s.rejectSessions--
+		}
+		s.mu.Unlock()
+		if reject {
+			newCh.Reject(ssh.ResourceShortage, "too many sessions")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		s.mu.Lock()
+		s.sessions++
+		s.mu.Unlock()
+		go s.handleSession(ch, chReqs)
+	}
+}
+
+func (s *testSSHServer) handleSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+
+		s.mu.Lock()
+		s.execs++
+		s.mu.Unlock()
+
+		go func(command string) {
+			status := uint32(0)
+			if command != testEnginePath {
+				ch.Stderr().Write([]byte(command + ": not found\n"))
+				status = 127
+			} else if err := s.engine.Run(ch, ch); err != nil {
+				status = 1
+			}
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			ch.Close()
+		}(payload.Command)
+	}
+}
+
+// dropConnections cuts every open connection, as if the host rebooted.
+func (s *testSSHServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+// connections returns how many TCP connections the server accepted.
+func (s *testSSHServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accepted
+}
+
+func (s *testSSHServer) stats() (execs, sessions int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.execs, s.sessions
+}
+
+func (s *testSSHServer) close() {
+	s.listener.Close()
+	s.dropConnections()
+}
+
+// adapterConfig returns adapter settings for this server, pinning its host key.
+func (s *testSSHServer) adapterConfig() config.Config {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	return config.Config{
+		SSHHost:                host,
+		SSHPort:                portNum,
+		SSHUser:                testUser,
+		SSHPassword:            testPassword,
+		SSHTimeout:             5 * time.Second,
+		SSHHostKeyFingerprints: []string{ssh.FingerprintSHA256(s.hostKey.PublicKey())},
+		SSHPoolSize:            2,
+		StockfishPath:          testEnginePath,
+		EnginePoolSize:         2,
+		EngineMaxSearches:      100,
+		EngineReadyTimeout:     5 * time.Second,
+		MaxDepth:               10,
+		AnalysisDepth:          3,
+	}
+}
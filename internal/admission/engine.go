@@ -0,0 +1,66 @@
+package admission
+
+import (
+	"context"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// Engine admits searches and sessions on a backend through a Limiter.
+// A session occupies its slot until it is closed.
+type Engine struct {
+	next    ports.EngineBackend
+	limiter *Limiter
+}
+
+func New(next ports.EngineBackend, limiter *Limiter) *Engine {
+	return &Engine{next: next, limiter: limiter}
+}
+
+func (e *Engine) Health(ctx context.Context) error {
+	return e.next.Health(ctx)
+}
+
+func (e *Engine) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	release, err := e.limiter.Acquire(ctx, req.Priority)
+	if err != nil {
+		return ports.AnalyzeResult{}, err
+	}
+	defer release()
+	return e.next.Analyze(ctx, req)
+}
+
+func (e *Engine) StartSession(ctx context.Context, req ports.AnalyzeRequest) (ports.EngineSession, error) {
+	release, err := e.limiter.Acquire(ctx, req.Priority)
+	if err != nil {
+		return nil, err
+	}
+	s, err := e.next.StartSession(ctx, req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &session{EngineSession: s, release: release}, nil
+}
+
+type session struct {
+	ports.EngineSession
+	release func()
+}
+
+func (s *session) Close() error {
+	err := s.EngineSession.Close()
+	s.release()
+	return err
+}
+
+// Stats reports the searches and sessions running and waiting.
+type Stats struct {
+	Active int `json:"active"`
+	Queued int `json:"queued"`
+}
+
+func (e *Engine) Stats() Stats {
+	active, queued := e.limiter.Stats()
+	return Stats{Active: active, Queued: queued}
+}
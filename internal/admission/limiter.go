@@ -0,0 +1,208 @@
+package admission
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// classes lists the priorities from most to least urgent.
+var classes = []string{ports.PriorityInteractive, ports.PriorityNormal, ports.PriorityBatch}
+
+func classIndex(priority string) int {
+	for i, c := range classes {
+		if c == priority {
+			return i
+		}
+	}
+	return 1
+}
+
+// Limiter bounds how many searches run on a backend at once. Callers beyond
+// the limit wait for up to queueTimeout in per-priority FIFO queues holding
+// at most maxQueue entries in total. Free slots go to the most urgent class
+// that is under its cap, except that a caller waiting longer than
+// starvationAge is served first whatever its class.
+type Limiter struct {
+	maxActive     int
+	maxQueue      int
+	queueTimeout  time.Duration
+	caps          []int
+	starvationAge time.Duration
+
+	mu          sync.Mutex
+	active      int
+	classActive []int
+	waiting     []*list.List
+	queued      int
+}
+
+type waiter struct {
+	class    int
+	queuedAt time.Time
+	ready    chan struct{}
+}
+
+func NewLimiter(maxActive, maxQueue int, queueTimeout time.Duration, classCaps map[string]int, starvationAge time.Duration) *Limiter {
+	if maxActive < 1 {
+		maxActive = 1
+	}
+	l := &Limiter{
+		maxActive:     maxActive,
+		maxQueue:      maxQueue,
+		queueTimeout:  queueTimeout,
+		caps:          make([]int, len(classes)),
+		starvationAge: starvationAge,
+		classActive:   make([]int, len(classes)),
+		waiting:       make([]*list.List, len(classes)),
+	}
+	for i, c := range classes {
+		l.caps[i] = classCaps[c]
+		l.waiting[i] = list.New()
+	}
+	return l
+}
+
+// Acquire waits for a free slot for a search of the given priority. The
+// returned release must be called once the work is done.
+func (l *Limiter) Acquire(ctx context.Context, priority string) (func(), error) {
+	w := &waiter{class: classIndex(priority), queuedAt: time.Now(), ready: make(chan struct{})}
+
+	l.mu.Lock()
+	el := l.waiting[w.class].PushBack(w)
+	l.queued++
+	l.dispatch()
+	if l.granted(w) {
+		l.mu.Unlock()
+		return l.releaseFunc(w.class), nil
+	}
+	if l.queued > l.maxQueue {
+		l.waiting[w.class].Remove(el)
+		l.queued--
+		l.mu.Unlock()
+		return nil, &ports.OverloadedError{QueueFull: true, RetryAfter: l.retryAfter()}
+	}
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if l.queueTimeout > 0 {
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-w.ready:
+		return l.releaseFunc(w.class), nil
+	case <-timeout:
+		if l.abandon(el, w) {
+			return l.releaseFunc(w.class), nil
+		}
+		return nil, &ports.OverloadedError{Waited: l.queueTimeout, RetryAfter: l.retryAfter()}
+	case <-ctx.Done():
+		if l.abandon(el, w) {
+			l.release(w.class)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (l *Limiter) granted(w *waiter) bool {
+	select {
+	case <-w.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// abandon removes a waiter from the queue. It reports true when the slot
+// was handed over in the meantime, in which case the caller owns it.
+func (l *Limiter) abandon(el *list.Element, w *waiter) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.granted(w) {
+		return true
+	}
+	l.waiting[w.class].Remove(el)
+	l.queued--
+	return false
+}
+
+// dispatch hands free slots to waiters. It must be called with l.mu held.
+func (l *Limiter) dispatch() {
+	for l.active < l.maxActive {
+		el := l.next()
+		if el == nil {
+			return
+		}
+		w := el.Value.(*waiter)
+		l.waiting[w.class].Remove(el)
+		l.queued--
+		l.active++
+		l.classActive[w.class]++
+		close(w.ready)
+	}
+}
+
+// next picks the waiter to serve: the oldest starving one if any, otherwise
+// the head of the most urgent queue whose class is under its cap.
+func (l *Limiter) next() *list.Element {
+	var starving *list.Element
+	if l.starvationAge > 0 {
+		now := time.Now()
+		for class, q := range l.waiting {
+			front := q.Front()
+			if front == nil || !l.underCap(class) {
+				continue
+			}
+			w := front.Value.(*waiter)
+			if now.Sub(w.queuedAt) >= l.starvationAge && (starving == nil || w.queuedAt.Before(starving.Value.(*waiter).queuedAt)) {
+				starving = front
+			}
+		}
+	}
+	if starving != nil {
+		return starving
+	}
+	for class, q := range l.waiting {
+		if front := q.Front(); front != nil && l.underCap(class) {
+			return front
+		}
+	}
+	return nil
+}
+
+func (l *Limiter) underCap(class int) bool {
+	return l.caps[class] <= 0 || l.classActive[class] < l.caps[class]
+}
+
+func (l *Limiter) releaseFunc(class int) func() {
+	var once sync.Once
+	return func() { once.Do(func() { l.release(class) }) }
+}
+
+func (l *Limiter) release(class int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.classActive[class]--
+	l.dispatch()
+}
+
+// Stats reports the running and queued searches.
+func (l *Limiter) Stats() (active, queued int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active, l.queued
+}
+
+func (l *Limiter) retryAfter() time.Duration {
+	if l.queueTimeout > time.Second {
+		return l.queueTimeout
+	}
+	return time.Second
+}
@@ -0,0 +1,166 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+func TestLimiter_QueueFull(t *testing.T) {
+	l := NewLimiter(1, 1, time.Second, nil, 0)
+	release, err := l.Acquire(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queued := make(chan error, 1)
+	go func() {
+		r, err := l.Acquire(context.Background(), "")
+		if err == nil {
+			r()
+		}
+		queued <- err
+	}()
+	waitForQueue(t, l, 1)
+
+	_, err = l.Acquire(context.Background(), "")
+	var overloaded *ports.OverloadedError
+	if !errors.As(err, &overloaded) || !overloaded.QueueFull {
+		t.Fatalf("expected a queue-full OverloadedError, got %v", err)
+	}
+	if overloaded.RetryAfter <= 0 {
+		t.Error("expected a Retry-After hint")
+	}
+
+	release()
+	if err := <-queued; err != nil {
+		t.Errorf("queued caller should get the released slot: %v", err)
+	}
+	if active, queued := l.Stats(); active != 0 || queued != 0 {
+		t.Errorf("Stats = %d active, %d queued after all releases", active, queued)
+	}
+}
+
+func TestLimiter_QueueTimeout(t *testing.T) {
+	l := NewLimiter(1, 4, 20*time.Millisecond, nil, 0)
+	release, _ := l.Acquire(context.Background(), "")
+	defer release()
+
+	_, err := l.Acquire(context.Background(), "")
+	if !errors.Is(err, ports.ErrOverloaded) {
+		t.Fatalf("expected ErrOverloaded, got %v", err)
+	}
+	var overloaded *ports.OverloadedError
+	if errors.As(err, &overloaded) && overloaded.QueueFull {
+		t.Error("a timed-out wait is not a full queue")
+	}
+	if _, queued := l.Stats(); queued != 0 {
+		t.Errorf("timed-out caller still queued")
+	}
+}
+
+func TestLimiter_Canceled(t *testing.T) {
+	l := NewLimiter(1, 4, time.Second, nil, 0)
+	release, _ := l.Acquire(context.Background(), "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(ctx, "")
+		done <- err
+	}()
+	waitForQueue(t, l, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	release()
+	if active, _ := l.Stats(); active != 0 {
+		t.Errorf("slot leaked: %d active", active)
+	}
+}
+
+func waitForQueue(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, queued := l.Stats(); queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queue never reached %d", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// acquireAsync queues a caller and reports its class on order once served.
+func acquireAsync(t *testing.T, l *Limiter, priority string, order chan<- string) {
+	t.Helper()
+	_, before := l.Stats()
+	go func() {
+		release, err := l.Acquire(context.Background(), priority)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		order <- priority
+		release()
+	}()
+	waitForQueue(t, l, before+1)
+}
+
+func TestLimiter_PriorityOrder(t *testing.T) {
+	l := NewLimiter(1, 8, time.Second, nil, 0)
+	release, _ := l.Acquire(context.Background(), ports.PriorityNormal)
+
+	order := make(chan string, 3)
+	acquireAsync(t, l, ports.PriorityBatch, order)
+	acquireAsync(t, l, ports.PriorityNormal, order)
+	acquireAsync(t, l, ports.PriorityInteractive, order)
+	release()
+
+	want := []string{ports.PriorityInteractive, ports.PriorityNormal, ports.PriorityBatch}
+	for _, w := range want {
+		if got := <-order; got != w {
+			t.Fatalf("served %s, want %s", got, w)
+		}
+	}
+}
+
+func TestLimiter_ClassCap(t *testing.T) {
+	l := NewLimiter(2, 8, time.Second, map[string]int{ports.PriorityBatch: 1}, 0)
+	release, _ := l.Acquire(context.Background(), ports.PriorityBatch)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, ports.PriorityBatch); err == nil {
+		t.Fatal("a second batch search should wait for the batch cap")
+	}
+	other, err := l.Acquire(context.Background(), ports.PriorityNormal)
+	if err != nil {
+		t.Fatalf("normal search should use the free slot: %v", err)
+	}
+	other()
+}
+
+func TestLimiter_StarvationProtection(t *testing.T) {
+	l := NewLimiter(1, 8, time.Second, nil, 10*time.Millisecond)
+	release, _ := l.Acquire(context.Background(), ports.PriorityNormal)
+
+	order := make(chan string, 2)
+	acquireAsync(t, l, ports.PriorityBatch, order)
+	time.Sleep(20 * time.Millisecond)
+	acquireAsync(t, l, ports.PriorityInteractive, order)
+	release()
+
+	if got := <-order; got != ports.PriorityBatch {
+		t.Fatalf("starving batch search should go first, got %s", got)
+	}
+	<-order
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// flight is one engine search shared by every concurrent request with the
+// same key. It is canceled only when all of its callers have given up.
+type flight struct {
+	done   chan struct{}
+	result ports.AnalyzeResult
+	err    error
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	callers int
+	subs    map[int]func(ports.AnalysisUpdate)
+	nextSub int
+	last    *ports.AnalysisUpdate
+}
+
+func (f *flight) broadcast(u ports.AnalysisUpdate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.last = &u
+	for _, fn := range f.subs {
+		fn(u)
+	}
+}
+
+// join registers a caller, replaying the newest update to late streaming
+// subscribers, and returns the id needed to leave.
+func (f *flight) join(progress func(ports.AnalysisUpdate)) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.callers++
+	if progress == nil {
+		return -1
+	}
+	id := f.nextSub
+	f.nextSub++
+	f.subs[id] = progress
+	if f.last != nil {
+		progress(*f.last)
+	}
+	return id
+}
+
+// leave unregisters a caller and reports whether it was the last one.
+func (f *flight) leave(id int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subs, id)
+	f.callers--
+	return f.callers == 0
+}
+
+type coalescer struct {
+	engine  ports.StockfishEnginePort
+	keyOf   func(ports.AnalyzeRequest) (string, error)
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+func (c *coalescer) analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	key, err := c.keyOf(req)
+	if err != nil {
+		return c.engine.Analyze(ctx, req)
+	}
+	// A flight is admitted with its first caller's priority, so only
+	// requests of the same class may share it.
+	priority := req.Priority
+	if priority == "" {
+		priority = ports.PriorityNormal
+	}
+	key = priority + "|" + key
+
+	c.mu.Lock()
+	f, ok := c.flights[key]
+	if !ok {
+		fctx, cancel := context.WithCancel(context.Background())
+		f = &flight{done: make(chan struct{}), cancel: cancel, subs: map[int]func(ports.AnalysisUpdate){}}
+		c.flights[key] = f
+		go c.run(fctx, key, f, req)
+	}
+	id := f.join(req.Progress)
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+		f.leave(id)
+		return f.result, f.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		if f.leave(id) {
+			// Nobody is waiting any more: stop the search and make sure new
+			// requests start a fresh one instead of joining this one.
+			if c.flights[key] == f {
+				delete(c.flights, key)
+			}
+			f.cancel()
+		}
+		c.mu.Unlock()
+		return ports.AnalyzeResult{}, ctx.Err()
+	}
+}
+
+func (c *coalescer) run(ctx context.Context, key string, f *flight, req ports.AnalyzeRequest) {
+	defer f.cancel()
+	req.Progress = f.broadcast
+	f.result, f.err = c.engine.Analyze(ctx, req)
+
+	c.mu.Lock()
+	if c.flights[key] == f {
+		delete(c.flights, key)
+	}
+	c.mu.Unlock()
+	close(f.done)
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// blockingEngine holds every search until release is closed.
+type blockingEngine struct {
+	calls    atomic.Int32
+	started  chan struct{}
+	release  chan struct{}
+	canceled atomic.Int32
+}
+
+func newBlockingEngine() *blockingEngine {
+	return &blockingEngine{started: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+func (e *blockingEngine) Health(ctx context.Context) error { return nil }
+
+func (e *blockingEngine) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	e.calls.Add(1)
+	if req.Progress != nil {
+		req.Progress(ports.AnalysisUpdate{Depth: 1})
+	}
+	e.started <- struct{}{}
+	select {
+	case <-e.release:
+		return ports.AnalyzeResult{BestMoveUCI: "e2e4"}, nil
+	case <-ctx.Done():
+		e.canceled.Add(1)
+		return ports.AnalyzeResult{}, ctx.Err()
+	}
+}
+
+func fenKey(req ports.AnalyzeRequest) (string, error) {
+	return req.FEN, nil
+}
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+func TestChessService_CoalescesIdenticalRequests(t *testing.T) {
+	eng := newBlockingEngine()
+	svc := NewChessService(eng, fenKey)
+
+	const n = 5
+	var wg sync.WaitGroup
+	var updates atomic.Int32
+	results := make([]ports.AnalyzeResult, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := ports.AnalyzeRequest{FEN: startFEN}
+			if i == 0 {
+				req.Progress = func(ports.AnalysisUpdate) { updates.Add(1) }
+			}
+			res, err := svc.Analyze(context.Background(), req)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = res
+		}(i)
+	}
+
+	<-eng.started
+	// Give the remaining requests time to join the running search.
+	time.Sleep(50 * time.Millisecond)
+	close(eng.release)
+	wg.Wait()
+
+	if got := eng.calls.Load(); got != 1 {
+		t.Errorf("engine searched %d times, want 1", got)
+	}
+	for i, res := range results {
+		if res.BestMoveUCI != "e2e4" {
+			t.Errorf("result %d = %+v", i, res)
+		}
+	}
+	if updates.Load() == 0 {
+		t.Error("streaming subscriber received no updates")
+	}
+}
+
+func TestChessService_CoalescedSearchSurvivesOneCaller(t *testing.T) {
+	eng := newBlockingEngine()
+	svc := NewChessService(eng, fenKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := svc.Analyze(ctx, ports.AnalyzeRequest{FEN: startFEN})
+		firstErr <- err
+	}()
+	<-eng.started
+
+	second := make(chan ports.AnalyzeResult, 1)
+	go func() {
+		res, _ := svc.Analyze(context.Background(), ports.AnalyzeRequest{FEN: startFEN})
+		second <- res
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	if err := <-firstErr; err == nil {
+		t.Error("canceled caller should get an error")
+	}
+	close(eng.release)
+	if res := <-second; res.BestMoveUCI != "e2e4" {
+		t.Errorf("remaining caller got %+v", res)
+	}
+	if eng.canceled.Load() != 0 {
+		t.Error("search was canceled while a caller was still waiting")
+	}
+}
+
+func TestChessService_LastCallerCancelsSearch(t *testing.T) {
+	eng := newBlockingEngine()
+	svc := NewChessService(eng, fenKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		svc.Analyze(ctx, ports.AnalyzeRequest{FEN: startFEN})
+		close(done)
+	}()
+	<-eng.started
+	cancel()
+	<-done
+
+	deadline := time.Now().Add(time.Second)
+	for eng.canceled.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if eng.canceled.Load() != 1 {
+		t.Error("search should be canceled once nobody waits for it")
+	}
+}
+
+func TestChessService_CoalescesByPriority(t *testing.T) {
+	eng := newBlockingEngine()
+	svc := NewChessService(eng, fenKey)
+
+	var wg sync.WaitGroup
+	for _, priority := range []string{ports.PriorityBatch, ports.PriorityInteractive, "", ports.PriorityNormal} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.Analyze(context.Background(), ports.AnalyzeRequest{FEN: startFEN, Priority: priority}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// Batch, interactive and normal each start a search; the request
+	// without a priority joins the normal one.
+	for range 3 {
+		<-eng.started
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(eng.release)
+	wg.Wait()
+
+	if got := eng.calls.Load(); got != 3 {
+		t.Errorf("engine searched %d times, want 3", got)
+	}
+}
@@ -0,0 +1,217 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobQueueFull = errors.New("job queue is full")
+)
+
+const (
+	JobQueued   = "queued"
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+// JobInfo is the state of an asynchronous analysis. Progress holds the
+// newest engine update while the job runs.
+type JobInfo struct {
+	ID         string                `json:"id"`
+	Status     string                `json:"status"`
+	CreatedAt  time.Time             `json:"createdAt"`
+	StartedAt  *time.Time            `json:"startedAt,omitempty"`
+	FinishedAt *time.Time            `json:"finishedAt,omitempty"`
+	Progress   *ports.AnalysisUpdate `json:"progress,omitempty"`
+	Result     *ports.AnalyzeResult  `json:"result,omitempty"`
+	Error      string                `json:"error,omitempty"`
+}
+
+type job struct {
+	info   JobInfo
+	req    ports.AnalyzeRequest
+	cancel context.CancelFunc
+}
+
+// JobManager queues analyses and runs them on a fixed number of workers.
+// Finished jobs are kept for retention so clients can collect the result.
+type JobManager struct {
+	engine    ports.StockfishEnginePort
+	retention time.Duration
+
+	mu    sync.Mutex
+	jobs  map[string]*job
+	queue chan *job
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func NewJobManager(engine ports.StockfishEnginePort, workers, queueSize int, retention time.Duration) *JobManager {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	m := &JobManager{
+		engine:    engine,
+		retention: retention,
+		jobs:      map[string]*job{},
+		queue:     make(chan *job, queueSize),
+		done:      make(chan struct{}),
+	}
+	m.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go m.work()
+	}
+	if retention > 0 {
+		go m.expireFinished()
+	}
+	return m
+}
+
+func (m *JobManager) Submit(req ports.AnalyzeRequest) (JobInfo, error) {
+	if err := validateRequest(req); err != nil {
+		return JobInfo{}, err
+	}
+	// Jobs are bulk work unless the client says otherwise.
+	if req.Priority == "" {
+		req.Priority = ports.PriorityBatch
+	}
+
+	j := &job{info: JobInfo{ID: newID(), Status: JobQueued, CreatedAt: time.Now()}, req: req}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case m.queue <- j:
+	default:
+		return JobInfo{}, ErrJobQueueFull
+	}
+	m.jobs[j.info.ID] = j
+	return j.info, nil
+}
+
+func (m *JobManager) Get(id string) (JobInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return JobInfo{}, ErrJobNotFound
+	}
+	return j.info, nil
+}
+
+// Cancel stops a queued or running job. A finished job is removed instead.
+func (m *JobManager) Cancel(id string) (JobInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return JobInfo{}, ErrJobNotFound
+	}
+	switch j.info.Status {
+	case JobQueued:
+		j.finish(JobCanceled)
+	case JobRunning:
+		j.finish(JobCanceled)
+		j.cancel()
+	default:
+		delete(m.jobs, id)
+	}
+	return j.info, nil
+}
+
+func (m *JobManager) Close() {
+	close(m.done)
+	m.mu.Lock()
+	for _, j := range m.jobs {
+		if j.cancel != nil {
+			j.cancel()
+		}
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+func (m *JobManager) work() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.done:
+			return
+		case j := <-m.queue:
+			m.run(j)
+		}
+	}
+}
+
+func (m *JobManager) run(j *job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m.mu.Lock()
+	if j.info.Status != JobQueued {
+		m.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	j.info.Status = JobRunning
+	j.info.StartedAt = &now
+	j.cancel = cancel
+	m.mu.Unlock()
+
+	req := j.req
+	req.Progress = func(u ports.AnalysisUpdate) {
+		m.mu.Lock()
+		j.info.Progress = &u
+		m.mu.Unlock()
+	}
+	result, err := m.engine.Analyze(ctx, req)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if j.info.Status != JobRunning {
+		return
+	}
+	if err != nil {
+		j.info.Error = err.Error()
+		j.finish(JobFailed)
+		return
+	}
+	j.info.Result = &result
+	j.finish(JobDone)
+}
+
+// finish must be called with the manager's lock held.
+func (j *job) finish(status string) {
+	now := time.Now()
+	j.info.Status = status
+	j.info.FinishedAt = &now
+}
+
+func (m *JobManager) expireFinished() {
+	ticker := time.NewTicker(m.retention / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			for id, j := range m.jobs {
+				if j.info.FinishedAt != nil && now.Sub(*j.info.FinishedAt) >= m.retention {
+					delete(m.jobs, id)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+func waitForStatus(t *testing.T, m *JobManager, id, status string) JobInfo {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		info, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Status == status {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, want %s", id, info.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobManager_RunsJob(t *testing.T) {
+	eng := newBlockingEngine()
+	m := NewJobManager(eng, 1, 4, time.Minute)
+	defer m.Close()
+
+	info, err := m.Submit(ports.AnalyzeRequest{FEN: startFEN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-eng.started
+	running := waitForStatus(t, m, info.ID, JobRunning)
+	if running.Progress == nil || running.Progress.Depth != 1 {
+		t.Errorf("expected progress while running, got %+v", running.Progress)
+	}
+
+	close(eng.release)
+	done := waitForStatus(t, m, info.ID, JobDone)
+	if done.Result == nil || done.Result.BestMoveUCI != "e2e4" || done.FinishedAt == nil {
+		t.Errorf("unexpected finished job %+v", done)
+	}
+}
+
+func TestJobManager_CancelRunning(t *testing.T) {
+	eng := newBlockingEngine()
+	m := NewJobManager(eng, 1, 4, time.Minute)
+	defer m.Close()
+
+	info, _ := m.Submit(ports.AnalyzeRequest{FEN: startFEN})
+	<-eng.started
+	waitForStatus(t, m, info.ID, JobRunning)
+
+	canceled, err := m.Cancel(info.ID)
+	if err != nil || canceled.Status != JobCanceled {
+		t.Fatalf("Cancel = %+v, %v", canceled, err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for eng.canceled.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if eng.canceled.Load() != 1 {
+		t.Error("engine search was not canceled")
+	}
+	if got, _ := m.Get(info.ID); got.Status != JobCanceled {
+		t.Errorf("status = %s after the engine returned", got.Status)
+	}
+}
+
+func TestJobManager_QueueFull(t *testing.T) {
+	eng := newBlockingEngine()
+	m := NewJobManager(eng, 1, 1, time.Minute)
+	defer func() {
+		close(eng.release)
+		m.Close()
+	}()
+
+	m.Submit(ports.AnalyzeRequest{FEN: startFEN})
+	<-eng.started
+	if _, err := m.Submit(ports.AnalyzeRequest{FEN: startFEN}); err != nil {
+		t.Fatalf("second job should be queued: %v", err)
+	}
+	if _, err := m.Submit(ports.AnalyzeRequest{FEN: startFEN}); !errors.Is(err, ErrJobQueueFull) {
+		t.Fatalf("expected ErrJobQueueFull, got %v", err)
+	}
+	if _, err := m.Get("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+}
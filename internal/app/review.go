@@ -0,0 +1,215 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// Move classifications, from best to worst.
+const (
+	MoveBest       = "best"
+	MoveExcellent  = "excellent"
+	MoveGood       = "good"
+	MoveInaccuracy = "inaccuracy"
+	MoveMistake    = "mistake"
+	MoveBlunder    = "blunder"
+	MoveMissedMate = "missedMate"
+)
+
+const (
+	// mateScore stands in for a forced mate when a centipawn value is
+	// needed; mate in n counts as mateScore-n.
+	mateScore = 100000
+	// evalCap bounds evaluations before losses are computed, so that a
+	// won position thrown into a merely winning one is not a 10000 cp loss.
+	evalCap = 1000
+)
+
+// MoveReview grades one move of a game. Evaluations are from White's
+// perspective like those of Analyze; losses are from the mover's.
+type MoveReview struct {
+	Ply                  int     `json:"ply"`
+	MoveNumber           int     `json:"moveNumber"`
+	Color                string  `json:"color" enums:"white,black"`
+	MoveUCI              string  `json:"moveUci"`
+	MoveSAN              string  `json:"moveSan"`
+	BestMoveUCI          string  `json:"bestMoveUci,omitempty"`
+	BestMoveSAN          string  `json:"bestMoveSan,omitempty"`
+	EvaluationBeforeCp   *int    `json:"evaluationBeforeCp,omitempty"`
+	EvaluationBeforeMate *int    `json:"evaluationBeforeMate,omitempty"`
+	EvaluationAfterCp    *int    `json:"evaluationAfterCp,omitempty"`
+	EvaluationAfterMate  *int    `json:"evaluationAfterMate,omitempty"`
+	CentipawnLoss        int     `json:"centipawnLoss"`
+	WinChanceLoss        float64 `json:"winChanceLoss"`
+	Accuracy             float64 `json:"accuracy"`
+	Classification       string  `json:"classification" enums:"best,excellent,good,inaccuracy,mistake,blunder,missedMate"`
+}
+
+// PlayerReview sums up one side's moves. Accuracy is the mean of the move
+// accuracies.
+type PlayerReview struct {
+	Moves                int            `json:"moves"`
+	Accuracy             float64        `json:"accuracy"`
+	AverageCentipawnLoss float64        `json:"acpl"`
+	Classifications      map[string]int `json:"classifications"`
+}
+
+type GameReview struct {
+	White PlayerReview `json:"white"`
+	Black PlayerReview `json:"black"`
+	Moves []MoveReview `json:"moves"`
+}
+
+// Review analyzes every position of a game and grades each move against
+// the engine's choice in the position before it. The plies share the
+// review timeout, so a long game is searched less deeply instead of
+// holding the request open.
+func (s *ChessService) Review(ctx context.Context, req ports.AnalyzeRequest) (GameReview, error) {
+	req.TimeBudget = s.reviewTimeout
+	req.Plies = &ports.PlyRange{From: 0, To: -1}
+	req.MultiPV = 1
+	req.Progress = nil
+	result, err := s.Analyze(ctx, req)
+	if err != nil {
+		return GameReview{}, err
+	}
+	if len(result.Plies) < 2 {
+		return GameReview{}, &ports.InputError{Field: "pgn", Err: errors.New("the game has no moves to review")}
+	}
+
+	review := GameReview{
+		White: PlayerReview{Classifications: map[string]int{}},
+		Black: PlayerReview{Classifications: map[string]int{}},
+	}
+	var whiteCPL, blackCPL, whiteAcc, blackAcc float64
+	for i := 1; i < len(result.Plies); i++ {
+		m := reviewMove(result.Plies[i-1], result.Plies[i])
+		review.Moves = append(review.Moves, m)
+
+		player, cpl, acc := &review.White, &whiteCPL, &whiteAcc
+		if m.Color == "black" {
+			player, cpl, acc = &review.Black, &blackCPL, &blackAcc
+		}
+		player.Moves++
+		player.Classifications[m.Classification]++
+		*cpl += float64(m.CentipawnLoss)
+		*acc += m.Accuracy
+	}
+	for _, p := range []struct {
+		player   *PlayerReview
+		cpl, acc float64
+	}{{&review.White, whiteCPL, whiteAcc}, {&review.Black, blackCPL, blackAcc}} {
+		if p.player.Moves > 0 {
+			p.player.AverageCentipawnLoss = round1(p.cpl / float64(p.player.Moves))
+			p.player.Accuracy = round1(p.acc / float64(p.player.Moves))
+		}
+	}
+	return review, nil
+}
+
+func reviewMove(before, after ports.PlyResult) MoveReview {
+	white, moveNumber := sideAndMove(before.PositionFEN)
+	m := MoveReview{
+		Ply:                  after.Ply,
+		MoveNumber:           moveNumber,
+		Color:                "black",
+		MoveUCI:              after.MoveUCI,
+		MoveSAN:              after.MoveSAN,
+		BestMoveUCI:          before.BestMoveUCI,
+		BestMoveSAN:          before.BestMoveSAN,
+		EvaluationBeforeCp:   before.EvaluationCp,
+		EvaluationBeforeMate: before.EvaluationMate,
+		EvaluationAfterCp:    after.EvaluationCp,
+		EvaluationAfterMate:  after.EvaluationMate,
+	}
+	sign := -1
+	if white {
+		m.Color = "white"
+		sign = 1
+	}
+
+	// Scores from the mover's point of view.
+	scoreBefore := sign * whiteScore(before.AnalyzeResult)
+	scoreAfter := sign * whiteScore(after.AnalyzeResult)
+
+	m.CentipawnLoss = max(0, capEval(scoreBefore)-capEval(scoreAfter))
+	m.WinChanceLoss = round1(max(0, winChance(scoreBefore)-winChance(scoreAfter)))
+	m.Accuracy = round1(moveAccuracy(m.WinChanceLoss))
+
+	hadMate := scoreBefore > mateScore-1000
+	keptMate := scoreAfter > mateScore-1000
+	switch {
+	case m.MoveUCI == before.BestMoveUCI, scoreAfter == mateScore:
+		// Checkmate cannot be improved on, whatever the engine preferred.
+		m.Classification = MoveBest
+	case hadMate && !keptMate:
+		m.Classification = MoveMissedMate
+	case m.WinChanceLoss < 2:
+		m.Classification = MoveExcellent
+	case m.WinChanceLoss < 5:
+		m.Classification = MoveGood
+	case m.WinChanceLoss < 10:
+		m.Classification = MoveInaccuracy
+	case m.WinChanceLoss < 20:
+		m.Classification = MoveMistake
+	default:
+		m.Classification = MoveBlunder
+	}
+	return m
+}
+
+// whiteScore is the evaluation of r in centipawns from White's point of
+// view, with forced mates mapped near ±mateScore.
+func whiteScore(r ports.AnalyzeResult) int {
+	switch {
+	case r.EvaluationMate != nil && *r.EvaluationMate > 0:
+		return mateScore - *r.EvaluationMate
+	case r.EvaluationMate != nil && *r.EvaluationMate < 0:
+		return -mateScore - *r.EvaluationMate
+	case r.EvaluationMate != nil:
+		// Mate 0: the side to move has been mated.
+		if white, _ := sideAndMove(r.PositionFEN); white {
+			return -mateScore
+		}
+		return mateScore
+	case r.EvaluationCp != nil:
+		return *r.EvaluationCp
+	}
+	return 0
+}
+
+// sideAndMove reads whether White is to move, and the move number, from
+// a FEN.
+func sideAndMove(fen string) (white bool, moveNumber int) {
+	fields := strings.Fields(fen)
+	if len(fields) < 6 {
+		return true, 0
+	}
+	moveNumber, _ = strconv.Atoi(fields[5])
+	return fields[1] == "w", moveNumber
+}
+
+func capEval(cp int) int {
+	return min(evalCap, max(-evalCap, cp))
+}
+
+// winChance maps an evaluation to the mover's winning chances in percent,
+// using the logistic curve fitted to Lichess games.
+func winChance(cp int) float64 {
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(capEval(cp))))-1)
+}
+
+// moveAccuracy maps lost winning chances to a 0-100 accuracy, again after
+// Lichess.
+func moveAccuracy(winChanceLoss float64) float64 {
+	return min(100, max(0, 103.1668*math.Exp(-0.04354*winChanceLoss)-3.1669))
+}
+
+func round1(x float64) float64 {
+	return math.Round(x*10) / 10
+}
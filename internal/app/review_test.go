@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// gameEngine answers every request with the same per-ply analysis.
+type gameEngine struct {
+	plies []ports.PlyResult
+	req   ports.AnalyzeRequest
+}
+
+func (e *gameEngine) Health(ctx context.Context) error { return nil }
+
+func (e *gameEngine) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	e.req = req
+	return ports.AnalyzeResult{Plies: e.plies}, nil
+}
+
+func ply(n int, fen, move, best string, cp, mate *int) ports.PlyResult {
+	return ports.PlyResult{Ply: n, MoveUCI: move, MoveSAN: move, AnalyzeResult: ports.AnalyzeResult{
+		PositionFEN: fen, BestMoveUCI: best, EvaluationCp: cp, EvaluationMate: mate,
+	}}
+}
+
+func intp(n int) *int { return &n }
+
+func TestChessService_Review(t *testing.T) {
+	eng := &gameEngine{plies: []ports.PlyResult{
+		ply(0, "x w KQkq - 0 1", "", "e2e4", intp(30), nil),
+		ply(1, "x b KQkq - 0 1", "e2e4", "d7d5", intp(30), nil),
+		ply(2, "x w KQkq - 0 2", "e7e5", "g1f3", intp(30), nil),
+		ply(3, "x b KQkq - 1 2", "d1h5", "b8c6", intp(-200), nil),
+		ply(4, "x w KQkq - 0 3", "g7g6", "h5f7", nil, intp(1)),
+		ply(5, "x b KQkq - 0 3", "h5e5", "g8e7", intp(500), nil),
+	}}
+	svc := NewChessService(eng, nil)
+	svc.SetReviewTimeout(time.Minute)
+
+	review, err := svc.Review(context.Background(), ports.AnalyzeRequest{PGN: "1. e4 e5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eng.req.Plies == nil || eng.req.Plies.From != 0 || eng.req.Plies.To >= 0 {
+		t.Errorf("plies = %+v, want the whole game", eng.req.Plies)
+	}
+	if eng.req.TimeBudget != time.Minute {
+		t.Errorf("time budget = %v, want the review timeout", eng.req.TimeBudget)
+	}
+
+	want := []struct {
+		color, class string
+		number, cpl  int
+	}{
+		{"white", MoveBest, 1, 0},
+		{"black", MoveExcellent, 1, 0},
+		{"white", MoveBlunder, 2, 230},
+		{"black", MoveBlunder, 2, 1200},
+		{"white", MoveMissedMate, 3, 500},
+	}
+	if len(review.Moves) != len(want) {
+		t.Fatalf("got %d moves, want %d", len(review.Moves), len(want))
+	}
+	for i, w := range want {
+		m := review.Moves[i]
+		if m.Color != w.color || m.Classification != w.class || m.MoveNumber != w.number || m.CentipawnLoss != w.cpl {
+			t.Errorf("move %d = %+v, want %+v", i+1, m, w)
+		}
+	}
+	if m := review.Moves[0]; m.Accuracy != 100 || m.WinChanceLoss != 0 {
+		t.Errorf("best move accuracy = %v, loss = %v", m.Accuracy, m.WinChanceLoss)
+	}
+
+	if review.White.Moves != 3 || review.White.AverageCentipawnLoss != 243.3 || review.White.Classifications[MoveBlunder] != 1 {
+		t.Errorf("white = %+v", review.White)
+	}
+	if review.Black.Moves != 2 || review.Black.AverageCentipawnLoss != 600 {
+		t.Errorf("black = %+v", review.Black)
+	}
+	if review.White.Accuracy <= review.Black.Accuracy || review.Black.Accuracy <= 0 {
+		t.Errorf("accuracy white %v, black %v", review.White.Accuracy, review.Black.Accuracy)
+	}
+}
@@ -3,16 +3,35 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
 )
 
 type ChessService struct {
-	engine ports.StockfishEnginePort
+	engine        ports.StockfishEnginePort
+	coalescer     *coalescer
+	status        statusRegistry
+	reviewTimeout time.Duration
 }
 
-func NewChessService(engine ports.StockfishEnginePort) *ChessService {
-	return &ChessService{engine: engine}
+// NewChessService wraps engine. When keyOf is set, concurrent requests that
+// map to the same key share a single engine search.
+func NewChessService(engine ports.StockfishEnginePort, keyOf func(ports.AnalyzeRequest) (string, error)) *ChessService {
+	s := &ChessService{engine: engine}
+	if keyOf != nil {
+		s.coalescer = &coalescer{engine: engine, keyOf: keyOf, flights: map[string]*flight{}}
+	}
+	return s
+}
+
+// SetReviewTimeout bounds the total time of a game review; 0 leaves it to
+// the engine's own limits.
+func (s *ChessService) SetReviewTimeout(d time.Duration) {
+	s.reviewTimeout = d
 }
 
 func (s *ChessService) Health(ctx context.Context) error {
@@ -20,8 +39,119 @@ func (s *ChessService) Health(ctx context.Context) error {
 }
 
 func (s *ChessService) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	if err := validateRequest(req); err != nil {
+		return ports.AnalyzeResult{}, err
+	}
+	if err := validateLimits(req.Limits); err != nil {
+		return ports.AnalyzeResult{}, err
+	}
+	if req.MultiPV < 0 {
+		return ports.AnalyzeResult{}, &ports.InputError{Field: "multipv", Err: errors.New("must not be negative")}
+	}
+
+	var result ports.AnalyzeResult
+	var err error
+	if s.coalescer != nil {
+		result, err = s.coalescer.analyze(ctx, req)
+	} else {
+		result, err = s.engine.Analyze(ctx, req)
+	}
+	if err != nil && errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %w", ports.ErrTimeout, err)
+	}
+	return result, err
+}
+
+// validateRequest checks what every analysis needs: a position, well-formed
+// client text and a known priority.
+func validateRequest(req ports.AnalyzeRequest) error {
 	if req.FEN == "" && req.PGN == "" && req.UCIMoves == "" && req.SANMoves == "" {
-		return ports.AnalyzeResult{}, errors.New("fen, pgn, uci or san required")
+		return &ports.InputError{Err: errors.New("fen, pgn, uci or san required")}
+	}
+	if err := validateText(req); err != nil {
+		return err
+	}
+	return validatePriority(req.Priority)
+}
+
+const (
+	maxFENLength    = 128
+	maxMovesLength  = 16 << 10
+	maxPGNLength    = 1 << 20
+	maxOptionLength = 64
+)
+
+// validateText rejects client strings that no notation allows: oversized
+// values, invalid UTF-8 and control characters. Only a PGN may span lines;
+// every other field must be printable ASCII.
+func validateText(req ports.AnalyzeRequest) error {
+	if err := checkText("fen", req.FEN, maxFENLength, false); err != nil {
+		return err
+	}
+	if err := checkText("uci", req.UCIMoves, maxMovesLength, false); err != nil {
+		return err
+	}
+	if err := checkText("san", req.SANMoves, maxMovesLength, false); err != nil {
+		return err
+	}
+	if err := checkText("pgn", req.PGN, maxPGNLength, true); err != nil {
+		return err
+	}
+	if err := checkText("profile", req.Profile, maxOptionLength, false); err != nil {
+		return err
+	}
+	for k, v := range req.Options {
+		if err := checkText("options", k, maxOptionLength, false); err != nil {
+			return err
+		}
+		if err := checkText("options", v, maxOptionLength, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkText(field, value string, maxLen int, multiline bool) error {
+	if len(value) > maxLen {
+		return &ports.InputError{Field: field, Err: fmt.Errorf("longer than %d bytes", maxLen)}
+	}
+	if !utf8.ValidString(value) {
+		return &ports.InputError{Field: field, Err: errors.New("not valid UTF-8")}
+	}
+	for i, r := range value {
+		switch {
+		case multiline && (r == '\n' || r == '\r' || r == '\t'):
+		case unicode.IsControl(r):
+			return &ports.InputError{Field: field, Err: fmt.Errorf("control character %U at offset %d", r, i)}
+		case !multiline && r > unicode.MaxASCII:
+			return &ports.InputError{Field: field, Err: fmt.Errorf("non-ASCII character at offset %d", i)}
+		}
+	}
+	return nil
+}
+
+func validateLimits(l ports.SearchLimits) error {
+	for _, v := range []int{l.Depth, l.MoveTime, l.Nodes, l.Mate, l.WTime, l.BTime, l.WInc, l.BInc, l.MovesToGo} {
+		if v < 0 {
+			return &ports.InputError{Field: "limits", Err: errors.New("search limits must not be negative")}
+		}
+	}
+	// Increments and movestogo only make sense next to a clock.
+	switch {
+	case l.WInc > 0 && l.WTime == 0:
+		return &ports.InputError{Field: "winc", Err: errors.New("requires wtime")}
+	case l.BInc > 0 && l.BTime == 0:
+		return &ports.InputError{Field: "binc", Err: errors.New("requires btime")}
+	case l.MovesToGo > 0 && l.WTime == 0 && l.BTime == 0:
+		return &ports.InputError{Field: "movestogo", Err: errors.New("requires wtime or btime")}
+	}
+	return nil
+}
+
+func validatePriority(p string) error {
+	switch p {
+	case "", ports.PriorityInteractive, ports.PriorityNormal, ports.PriorityBatch:
+		return nil
 	}
-	return s.engine.Analyze(ctx, req)
+	return &ports.InputError{Field: "priority", Err: fmt.Errorf("unknown priority %q (want interactive, normal or batch)", p)}
 }
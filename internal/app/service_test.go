@@ -0,0 +1,63 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+func TestChessService_RejectsUnsafeText(t *testing.T) {
+	eng := newBlockingEngine()
+	close(eng.release)
+	svc := NewChessService(eng, nil)
+
+	bad := []ports.AnalyzeRequest{
+		{FEN: startFEN + "\nsetoption name Threads value 512"},
+		{UCIMoves: "e2e4\re7e5"},
+		{SANMoves: "e4\x00"},
+		{UCIMoves: "e2e4", Profile: "fast\nquit"},
+		{UCIMoves: "e2e4", Options: map[string]string{"Hash": "16\nquit"}},
+		{SANMoves: "e4 é5"},
+		{PGN: "1. e4 e5\x1b"},
+		{UCIMoves: strings.Repeat("e2e4 ", 4000)},
+	}
+	for _, req := range bad {
+		if _, err := svc.Analyze(context.Background(), req); !errors.Is(err, ports.ErrInvalidInput) {
+			t.Errorf("%q: want invalid input, got %v", req.FEN+req.UCIMoves+req.SANMoves+req.PGN, err)
+		}
+	}
+	if n := eng.calls.Load(); n != 0 {
+		t.Errorf("engine called %d times", n)
+	}
+
+	if _, err := svc.Analyze(context.Background(), ports.AnalyzeRequest{PGN: "[White \"Müller\"]\r\n\r\n1. e4\te5"}); err != nil {
+		t.Errorf("multi-line pgn rejected: %v", err)
+	}
+}
+
+func TestChessService_RejectsIncompleteClocks(t *testing.T) {
+	eng := newBlockingEngine()
+	close(eng.release)
+	svc := NewChessService(eng, nil)
+
+	for _, tt := range []struct {
+		limits ports.SearchLimits
+		field  string
+	}{
+		{ports.SearchLimits{WInc: 1000}, "winc"},
+		{ports.SearchLimits{WTime: 60000, BInc: 1000}, "binc"},
+		{ports.SearchLimits{MovesToGo: 40}, "movestogo"},
+	} {
+		_, err := svc.Analyze(context.Background(), ports.AnalyzeRequest{UCIMoves: "e2e4", Limits: tt.limits})
+		var input *ports.InputError
+		if !errors.As(err, &input) || input.Field != tt.field {
+			t.Errorf("%+v: err = %v, want invalid %s", tt.limits, err, tt.field)
+		}
+	}
+	if _, err := svc.Analyze(context.Background(), ports.AnalyzeRequest{UCIMoves: "e2e4", Limits: ports.SearchLimits{BTime: 60000, BInc: 1000, MovesToGo: 40}}); err != nil {
+		t.Errorf("complete clock rejected: %v", err)
+	}
+}
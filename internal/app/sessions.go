@@ -0,0 +1,219 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrTooManySessions = errors.New("too many analysis sessions")
+)
+
+// SessionInfo describes an infinite analysis session. Analysis is only
+// filled in when a single session is read.
+type SessionInfo struct {
+	ID          string               `json:"id"`
+	PositionFEN string               `json:"positionFen,omitempty"`
+	CreatedAt   time.Time            `json:"createdAt"`
+	LastAccess  time.Time            `json:"lastAccess"`
+	ExpiresAt   *time.Time           `json:"expiresAt,omitempty"`
+	Analysis    *ports.AnalyzeResult `json:"analysis,omitempty"`
+}
+
+type analysisSession struct {
+	id         string
+	engine     ports.EngineSession
+	createdAt  time.Time
+	lastAccess time.Time
+}
+
+// SessionManager keeps infinite analysis sessions, each holding a leased
+// engine, and closes the ones nobody has touched for idleTimeout.
+type SessionManager struct {
+	engine      ports.SessionEnginePort
+	idleTimeout time.Duration
+	maxSessions int
+
+	mu       sync.Mutex
+	sessions map[string]*analysisSession
+	starting int
+	done     chan struct{}
+}
+
+func NewSessionManager(engine ports.SessionEnginePort, idleTimeout time.Duration, maxSessions int) *SessionManager {
+	m := &SessionManager{
+		engine:      engine,
+		idleTimeout: idleTimeout,
+		maxSessions: maxSessions,
+		sessions:    map[string]*analysisSession{},
+		done:        make(chan struct{}),
+	}
+	if idleTimeout > 0 {
+		go m.expireIdle()
+	}
+	return m
+}
+
+func (m *SessionManager) Start(ctx context.Context, req ports.AnalyzeRequest) (SessionInfo, error) {
+	if err := validateRequest(req); err != nil {
+		return SessionInfo{}, err
+	}
+
+	m.mu.Lock()
+	if m.maxSessions > 0 && len(m.sessions)+m.starting >= m.maxSessions {
+		m.mu.Unlock()
+		return SessionInfo{}, ErrTooManySessions
+	}
+	m.starting++
+	m.mu.Unlock()
+
+	engineSession, err := m.engine.StartSession(ctx, req)
+
+	m.mu.Lock()
+	m.starting--
+	if err != nil {
+		m.mu.Unlock()
+		return SessionInfo{}, err
+	}
+	now := time.Now()
+	s := &analysisSession{id: newID(), engine: engineSession, createdAt: now, lastAccess: now}
+	m.sessions[s.id] = s
+	m.mu.Unlock()
+	return m.info(s, false), nil
+}
+
+func (m *SessionManager) Get(id string) (SessionInfo, error) {
+	s, err := m.touch(id)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	return m.info(s, true), nil
+}
+
+func (m *SessionManager) SetPosition(ctx context.Context, id string, req ports.AnalyzeRequest) (SessionInfo, error) {
+	s, err := m.touch(id)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	if err := s.engine.SetPosition(ctx, req); err != nil {
+		if errors.Is(err, ports.ErrSessionClosed) {
+			// The engine session is gone; so is ours.
+			m.mu.Lock()
+			if m.sessions[id] == s {
+				delete(m.sessions, id)
+			}
+			m.mu.Unlock()
+		}
+		return SessionInfo{}, err
+	}
+	return m.info(s, false), nil
+}
+
+// Stop ends the session and returns its final analysis.
+func (m *SessionManager) Stop(id string) (SessionInfo, error) {
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	if !ok {
+		return SessionInfo{}, ErrSessionNotFound
+	}
+	info := m.info(s, true)
+	return info, s.engine.Close()
+}
+
+func (m *SessionManager) List() []SessionInfo {
+	m.mu.Lock()
+	sessions := make([]*analysisSession, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.mu.Unlock()
+
+	out := make([]SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, m.info(s, false))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+func (m *SessionManager) Close() {
+	close(m.done)
+	m.mu.Lock()
+	sessions := m.sessions
+	m.sessions = map[string]*analysisSession{}
+	m.mu.Unlock()
+	for _, s := range sessions {
+		s.engine.Close()
+	}
+}
+
+func (m *SessionManager) touch(id string) (*analysisSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	s.lastAccess = time.Now()
+	return s, nil
+}
+
+func (m *SessionManager) info(s *analysisSession, withAnalysis bool) SessionInfo {
+	snapshot := s.engine.Snapshot()
+	m.mu.Lock()
+	info := SessionInfo{
+		ID:          s.id,
+		PositionFEN: snapshot.PositionFEN,
+		CreatedAt:   s.createdAt,
+		LastAccess:  s.lastAccess,
+	}
+	m.mu.Unlock()
+	if m.idleTimeout > 0 {
+		expires := info.LastAccess.Add(m.idleTimeout)
+		info.ExpiresAt = &expires
+	}
+	if withAnalysis {
+		info.Analysis = &snapshot
+	}
+	return info
+}
+
+func (m *SessionManager) expireIdle() {
+	ticker := time.NewTicker(m.idleTimeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-ticker.C:
+			var expired []*analysisSession
+			m.mu.Lock()
+			for id, s := range m.sessions {
+				if now.Sub(s.lastAccess) >= m.idleTimeout {
+					expired = append(expired, s)
+					delete(m.sessions, id)
+				}
+			}
+			m.mu.Unlock()
+			for _, s := range expired {
+				s.engine.Close()
+			}
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
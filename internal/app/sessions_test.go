@@ -0,0 +1,181 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// fakeSession reports the position it was last given as its analysis.
+// When broken, it closes itself on the next SetPosition like an engine
+// that failed to restart.
+type fakeSession struct {
+	mu     sync.Mutex
+	fen    string
+	closed bool
+	broken bool
+}
+
+func (s *fakeSession) Snapshot() ports.AnalyzeResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ports.AnalyzeResult{PositionFEN: s.fen, BestMoveUCI: "e2e4"}
+}
+
+func (s *fakeSession) SetPosition(ctx context.Context, req ports.AnalyzeRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.broken {
+		s.closed = true
+		return fmt.Errorf("%w: engine did not stop", ports.ErrSessionClosed)
+	}
+	s.fen = req.FEN
+	return nil
+}
+
+func (s *fakeSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *fakeSession) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+type sessionEngine struct {
+	mu       sync.Mutex
+	sessions []*fakeSession
+}
+
+func (e *sessionEngine) StartSession(ctx context.Context, req ports.AnalyzeRequest) (ports.EngineSession, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := &fakeSession{fen: req.FEN}
+	e.sessions = append(e.sessions, s)
+	return s, nil
+}
+
+const otherFEN = "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"
+
+func TestSessionManager_Lifecycle(t *testing.T) {
+	eng := &sessionEngine{}
+	m := NewSessionManager(eng, time.Minute, 1)
+	defer m.Close()
+	ctx := context.Background()
+
+	info, err := m.Start(ctx, ports.AnalyzeRequest{FEN: startFEN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID == "" || info.PositionFEN != startFEN || info.ExpiresAt == nil || info.Analysis != nil {
+		t.Errorf("start = %+v", info)
+	}
+	if _, err := m.Start(ctx, ports.AnalyzeRequest{FEN: startFEN}); !errors.Is(err, ErrTooManySessions) {
+		t.Errorf("second session: err = %v, want ErrTooManySessions", err)
+	}
+	if _, err := m.Start(ctx, ports.AnalyzeRequest{}); !errors.Is(err, ports.ErrInvalidInput) {
+		t.Errorf("no position: err = %v", err)
+	}
+
+	got, err := m.Get(info.ID)
+	if err != nil || got.Analysis == nil || got.Analysis.BestMoveUCI != "e2e4" {
+		t.Errorf("get = %+v, %v", got, err)
+	}
+	moved, err := m.SetPosition(ctx, info.ID, ports.AnalyzeRequest{FEN: otherFEN})
+	if err != nil || moved.PositionFEN != otherFEN {
+		t.Errorf("set position = %+v, %v", moved, err)
+	}
+	if list := m.List(); len(list) != 1 || list[0].ID != info.ID {
+		t.Errorf("list = %+v", list)
+	}
+
+	stopped, err := m.Stop(info.ID)
+	if err != nil || stopped.Analysis == nil || !eng.sessions[0].isClosed() {
+		t.Errorf("stop = %+v, %v", stopped, err)
+	}
+	if _, err := m.Get(info.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("get after stop: err = %v", err)
+	}
+	if _, err := m.Stop(info.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("second stop: err = %v", err)
+	}
+
+	// The slot is free again.
+	if _, err := m.Start(ctx, ports.AnalyzeRequest{FEN: startFEN}); err != nil {
+		t.Errorf("start after stop: %v", err)
+	}
+}
+
+func TestSessionManager_ExpiresIdleSessions(t *testing.T) {
+	eng := &sessionEngine{}
+	m := NewSessionManager(eng, 40*time.Millisecond, 0)
+	defer m.Close()
+
+	idle, err := m.Start(context.Background(), ports.AnalyzeRequest{FEN: startFEN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy, err := m.Start(context.Background(), ports.AnalyzeRequest{FEN: startFEN})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading a session keeps it alive.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := m.Get(busy.ID); err != nil {
+			t.Fatalf("busy session expired: %v", err)
+		}
+		if eng.sessions[0].isClosed() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("idle session never expired")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := m.Get(idle.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("get expired session: err = %v", err)
+	}
+	if list := m.List(); len(list) != 1 || list[0].ID != busy.ID || eng.sessions[1].isClosed() {
+		t.Errorf("sessions left = %+v", list)
+	}
+}
+
+func TestSessionManager_DropsClosedSessions(t *testing.T) {
+	eng := &sessionEngine{}
+	m := NewSessionManager(eng, time.Minute, 1)
+	defer m.Close()
+	ctx := context.Background()
+
+	info, err := m.Start(ctx, ports.AnalyzeRequest{FEN: startFEN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng.sessions[0].mu.Lock()
+	eng.sessions[0].broken = true
+	eng.sessions[0].mu.Unlock()
+
+	if _, err := m.SetPosition(ctx, info.ID, ports.AnalyzeRequest{FEN: otherFEN}); !errors.Is(err, ports.ErrSessionClosed) {
+		t.Fatalf("set position: err = %v, want ErrSessionClosed", err)
+	}
+	if _, err := m.Get(info.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("get closed session: err = %v", err)
+	}
+	if list := m.List(); len(list) != 0 {
+		t.Errorf("list = %+v", list)
+	}
+	// Its slot is free for a new session.
+	if _, err := m.Start(ctx, ports.AnalyzeRequest{FEN: startFEN}); err != nil {
+		t.Errorf("start after close: %v", err)
+	}
+}
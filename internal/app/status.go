@@ -0,0 +1,32 @@
+package app
+
+import "sync"
+
+// statusRegistry collects snapshots of the components wrapped around the
+// engine, such as the admission queue and the circuit breaker.
+type statusRegistry struct {
+	mu    sync.Mutex
+	funcs map[string]func() any
+}
+
+// AddStatus registers a component whose state is reported on /health and
+// /metrics. fn must return a JSON-friendly value.
+func (s *ChessService) AddStatus(name string, fn func() any) {
+	s.status.mu.Lock()
+	defer s.status.mu.Unlock()
+	if s.status.funcs == nil {
+		s.status.funcs = map[string]func() any{}
+	}
+	s.status.funcs[name] = fn
+}
+
+// Status returns the current snapshot of every registered component.
+func (s *ChessService) Status() map[string]any {
+	s.status.mu.Lock()
+	defer s.status.mu.Unlock()
+	out := make(map[string]any, len(s.status.funcs))
+	for name, fn := range s.status.funcs {
+		out[name] = fn()
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/engine"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// Engine answers repeated searches from a Store and forwards the rest to
+// the wrapped engine.
+type Engine struct {
+	next  ports.StockfishEnginePort
+	store Store
+	ttl   time.Duration
+	cfg   config.Config
+}
+
+func New(next ports.StockfishEnginePort, store Store, cfg config.Config) *Engine {
+	return &Engine{next: next, store: store, ttl: cfg.CacheTTL, cfg: cfg}
+}
+
+func (c *Engine) Health(ctx context.Context) error {
+	return c.next.Health(ctx)
+}
+
+func (c *Engine) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	key, err := engine.NewSearchKey(req, c.cfg)
+	if err != nil {
+		// Let the engine report the invalid request.
+		return c.next.Analyze(ctx, req)
+	}
+
+	entry, ok := c.store.Get(key.Key)
+	if ok && entry.Depth >= key.Depth && !c.expired(entry) {
+		result := entry.Result
+		result.PositionFEN = key.FEN
+		result.Draw = key.Draw
+		storedAt := entry.StoredAt
+		result.Cache = &ports.CacheInfo{Hit: true, Depth: entry.Depth, StoredAt: &storedAt}
+		return result, nil
+	}
+
+	result, err := c.next.Analyze(ctx, req)
+	if err != nil {
+		return result, err
+	}
+	// A search cut short by the time budget is stored at the depth it
+	// reached, so it cannot answer a request for more.
+	depth := min(key.Depth, result.Depth)
+	// Keep a deeper entry rather than replacing it with a shallower one.
+	if !ok || depth >= entry.Depth || c.expired(entry) {
+		stored := result
+		stored.Cache = nil
+		// A failed write only costs a future search.
+		_ = c.store.Put(Entry{Key: key.Key, Depth: depth, StoredAt: time.Now(), Result: stored})
+	}
+	result.Cache = &ports.CacheInfo{Hit: false}
+	return result, nil
+}
+
+func (c *Engine) expired(entry Entry) bool {
+	return c.ttl > 0 && time.Since(entry.StoredAt) > c.ttl
+}
@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+type countingEngine struct {
+	calls int
+	// reached, if set, is the depth every search stops at, as when the
+	// time budget cuts it short.
+	reached int
+}
+
+func (e *countingEngine) Health(ctx context.Context) error { return nil }
+
+func (e *countingEngine) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	e.calls++
+	depth := req.Limits.Depth
+	if depth == 0 {
+		depth = testConfig().AnalysisDepth
+	}
+	if e.reached > 0 {
+		depth = e.reached
+	}
+	return ports.AnalyzeResult{BestMoveUCI: "e2e4", Depth: depth}, nil
+}
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+func testConfig() config.Config {
+	return config.Config{AnalysisDepth: 12, MaxDepth: 30, MaxMultiPV: 5}
+}
+
+func analyze(t *testing.T, c *Engine, req ports.AnalyzeRequest) ports.AnalyzeResult {
+	t.Helper()
+	res, err := c.Analyze(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestEngine_DeeperSatisfiesShallower(t *testing.T) {
+	next := &countingEngine{}
+	c := New(next, NewLRU(10), testConfig())
+
+	deep := analyze(t, c, ports.AnalyzeRequest{FEN: startFEN, Limits: ports.SearchLimits{Depth: 20}})
+	if deep.Cache == nil || deep.Cache.Hit {
+		t.Fatalf("first search should miss, got %+v", deep.Cache)
+	}
+
+	// Move counters do not matter and depth 10 is covered by depth 20.
+	shallow := analyze(t, c, ports.AnalyzeRequest{
+		FEN:    "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 7 30",
+		Limits: ports.SearchLimits{Depth: 10},
+	})
+	if shallow.Cache == nil || !shallow.Cache.Hit || shallow.Cache.Depth != 20 {
+		t.Fatalf("expected a hit at depth 20, got %+v", shallow.Cache)
+	}
+	if shallow.PositionFEN != "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 7 30" {
+		t.Errorf("PositionFEN = %q, want the requested FEN", shallow.PositionFEN)
+	}
+
+	analyze(t, c, ports.AnalyzeRequest{FEN: startFEN, Limits: ports.SearchLimits{Depth: 25}})
+	if next.calls != 2 {
+		t.Errorf("engine called %d times, want 2", next.calls)
+	}
+}
+
+func TestEngine_KeyIncludesOptionsAndLines(t *testing.T) {
+	next := &countingEngine{}
+	c := New(next, NewLRU(10), testConfig())
+
+	base := ports.AnalyzeRequest{FEN: startFEN}
+	analyze(t, c, base)
+	analyze(t, c, ports.AnalyzeRequest{FEN: startFEN, MultiPV: 3})
+	analyze(t, c, ports.AnalyzeRequest{FEN: startFEN, Options: map[string]string{"skill level": "5"}})
+	analyze(t, c, ports.AnalyzeRequest{FEN: startFEN, Limits: ports.SearchLimits{MoveTime: 100}})
+	analyze(t, c, base)
+	if next.calls != 4 {
+		t.Errorf("engine called %d times, want 4", next.calls)
+	}
+}
+
+func TestEngine_DoesNotCacheAcrossTimedSearches(t *testing.T) {
+	next := &countingEngine{}
+	c := New(next, NewLRU(10), testConfig())
+
+	analyze(t, c, ports.AnalyzeRequest{FEN: startFEN, Limits: ports.SearchLimits{Depth: 20, MoveTime: 100}})
+	res := analyze(t, c, ports.AnalyzeRequest{FEN: startFEN, Limits: ports.SearchLimits{Depth: 10, MoveTime: 100}})
+	if res.Cache.Hit {
+		t.Error("a time-limited search must only match the same limits")
+	}
+}
+
+func TestEngine_StoresReachedDepth(t *testing.T) {
+	next := &countingEngine{reached: 8}
+	c := New(next, NewLRU(10), testConfig())
+
+	analyze(t, c, ports.AnalyzeRequest{FEN: startFEN, Limits: ports.SearchLimits{Depth: 20}})
+	if res := analyze(t, c, ports.AnalyzeRequest{FEN: startFEN, Limits: ports.SearchLimits{Depth: 20}}); res.Cache.Hit {
+		t.Error("a search cut short at depth 8 answered a depth 20 request")
+	}
+	res := analyze(t, c, ports.AnalyzeRequest{FEN: startFEN, Limits: ports.SearchLimits{Depth: 8}})
+	if !res.Cache.Hit || res.Cache.Depth != 8 {
+		t.Errorf("depth 8 request: cache = %+v", res.Cache)
+	}
+	if next.calls != 2 {
+		t.Errorf("engine called %d times, want 2", next.calls)
+	}
+}
+
+func TestLRU_Evicts(t *testing.T) {
+	c := NewLRU(2)
+	c.Put(Entry{Key: "a"})
+	c.Put(Entry{Key: "b"})
+	c.Get("a")
+	c.Put(Entry{Key: "c"})
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry should be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("recently used entry should be kept")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+}
+
+func TestDisk_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDisk(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put(Entry{Key: "k", Depth: 14, Result: ports.AnalyzeResult{BestMoveUCI: "d2d4"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, _ := NewDisk(dir, 10)
+	entry, ok := reopened.Get("k")
+	if !ok || entry.Depth != 14 || entry.Result.BestMoveUCI != "d2d4" {
+		t.Fatalf("Get = %+v, %v", entry, ok)
+	}
+	if _, ok := reopened.Get("other"); ok {
+		t.Error("unexpected hit for a missing key")
+	}
+}
+
+func TestDisk_Evicts(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDisk(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Put(Entry{Key: "a"})
+	d.Put(Entry{Key: "b"})
+	hourAgo := time.Now().Add(-time.Hour)
+	os.Chtimes(d.path("a"), hourAgo, hourAgo)
+	os.Chtimes(d.path("b"), hourAgo.Add(time.Minute), hourAgo.Add(time.Minute))
+
+	d.Get("a")
+	d.Put(Entry{Key: "c"})
+	if _, ok := d.Get("b"); ok {
+		t.Error("least recently used entry should be evicted")
+	}
+	if _, ok := d.Get("a"); !ok {
+		t.Error("recently used entry should be kept")
+	}
+
+	// The count survives a restart.
+	reopened, _ := NewDisk(dir, 2)
+	reopened.Put(Entry{Key: "d"})
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Errorf("%d files on disk, want 2", len(files))
+	}
+}
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Disk stores one JSON file per entry so cached analyses survive restarts.
+// Beyond size entries the least recently used files are removed; reads
+// touch a file's modification time.
+type Disk struct {
+	dir  string
+	size int
+
+	mu    sync.Mutex
+	count int
+}
+
+func NewDisk(dir string, size int) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if size < 1 {
+		size = 1
+	}
+	d := &Disk{dir: dir, size: size}
+	files, err := d.entries()
+	if err != nil {
+		return nil, err
+	}
+	d.count = len(files)
+	return d, nil
+}
+
+func (d *Disk) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (d *Disk) Get(key string) (Entry, bool) {
+	path := d.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, false
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return Entry{}, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return entry, true
+}
+
+// Put writes through a temporary file so readers never see a partial entry.
+func (d *Disk) Put(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(d.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	path := d.path(entry.Key)
+	_, statErr := os.Stat(path)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if statErr != nil {
+		d.count++
+	}
+	if d.count > d.size {
+		return d.evict()
+	}
+	return nil
+}
+
+// evict removes the least recently used entries down to the size limit.
+// d.mu must be held.
+func (d *Disk) evict() error {
+	files, err := d.entries()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for len(files) > d.size {
+		if err := os.Remove(filepath.Join(d.dir, files[0].Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		files = files[1:]
+	}
+	d.count = len(files)
+	return nil
+}
+
+func (d *Disk) entries() ([]os.FileInfo, error) {
+	dirEntries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []os.FileInfo
+	for _, e := range dirEntries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+	}
+	return files, nil
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// Entry is a stored analysis together with the depth it was searched to.
+type Entry struct {
+	Key      string              `json:"key"`
+	Depth    int                 `json:"depth"`
+	StoredAt time.Time           `json:"storedAt"`
+	Result   ports.AnalyzeResult `json:"result"`
+}
+
+type Store interface {
+	Get(key string) (Entry, bool)
+	Put(entry Entry) error
+}
+
+// LRU keeps the most recently used entries in memory.
+type LRU struct {
+	size  int
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+func NewLRU(size int) *LRU {
+	if size < 1 {
+		size = 1
+	}
+	return &LRU{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func (c *LRU) Get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(Entry), true
+}
+
+func (c *LRU) Put(entry Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[entry.Key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return nil
+	}
+	c.items[entry.Key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(Entry).Key)
+	}
+	return nil
+}
+
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,283 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+var errNoHealthyHosts = errors.New("no healthy engine hosts")
+
+// Member is one engine host of the cluster.
+type Member struct {
+	Name          string
+	Backend       ports.EngineBackend
+	Weight        int
+	MaxConcurrent int
+	Tags          map[string]string
+}
+
+type member struct {
+	Member
+	inflight  int
+	healthy   bool
+	failures  int
+	lastError string
+	checkedAt time.Time
+}
+
+// Cluster routes each search to the least-loaded healthy host, relative to
+// its weight, and fails it over to another host when the first one breaks
+// or turns it away. Hosts are ejected when a probe fails or after
+// ejectAfter consecutive failed searches, and return once a background
+// probe succeeds.
+type Cluster struct {
+	ejectAfter    int
+	probeInterval time.Duration
+	probeTimeout  time.Duration
+
+	mu      sync.Mutex
+	members []*member
+	done    chan struct{}
+}
+
+func New(members []Member, ejectAfter int, probeInterval, probeTimeout time.Duration) *Cluster {
+	if ejectAfter < 1 {
+		ejectAfter = 1
+	}
+	c := &Cluster{ejectAfter: ejectAfter, probeInterval: probeInterval, probeTimeout: probeTimeout, done: make(chan struct{})}
+	for _, m := range members {
+		if m.Weight < 1 {
+			m.Weight = 1
+		}
+		c.members = append(c.members, &member{Member: m, healthy: true})
+	}
+	if probeInterval > 0 {
+		go c.probeLoop()
+	}
+	return c
+}
+
+func (c *Cluster) Close() {
+	close(c.done)
+}
+
+// Health probes every host and succeeds when at least one is healthy.
+func (c *Cluster) Health(ctx context.Context) error {
+	c.probe(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range c.members {
+		if m.healthy {
+			return nil
+		}
+	}
+	return &ports.UnavailableError{Err: errNoHealthyHosts}
+}
+
+func (c *Cluster) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	tried := map[*member]bool{}
+	var lastErr error
+	for {
+		m := c.acquire(tried)
+		if m == nil {
+			if lastErr == nil {
+				lastErr = &ports.UnavailableError{Err: errNoHealthyHosts}
+			}
+			return ports.AnalyzeResult{}, lastErr
+		}
+		result, err := m.Backend.Analyze(ctx, req)
+		c.release(m)
+		if !c.failover(ctx, m, err) {
+			return result, err
+		}
+		lastErr = err
+	}
+}
+
+func (c *Cluster) StartSession(ctx context.Context, req ports.AnalyzeRequest) (ports.EngineSession, error) {
+	tried := map[*member]bool{}
+	var lastErr error
+	for {
+		m := c.acquire(tried)
+		if m == nil {
+			if lastErr == nil {
+				lastErr = &ports.UnavailableError{Err: errNoHealthyHosts}
+			}
+			return nil, lastErr
+		}
+		s, err := m.Backend.StartSession(ctx, req)
+		if err == nil {
+			return &session{EngineSession: s, release: func() { c.release(m) }}, nil
+		}
+		c.release(m)
+		if !c.failover(ctx, m, err) {
+			return nil, err
+		}
+		lastErr = err
+	}
+}
+
+// failover records the outcome of a search on m and reports whether the
+// work should move to another host: when m could not be reached or refused
+// the search for lack of capacity. Only the former counts towards ejecting
+// m; a permanent failure ejects it at once.
+func (c *Cluster) failover(ctx context.Context, m *member, err error) bool {
+	if err == nil {
+		c.mu.Lock()
+		m.failures = 0
+		c.mu.Unlock()
+		return false
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ports.ErrOverloaded) {
+		return true
+	}
+	if !errors.Is(err, ports.ErrUnavailable) {
+		return false
+	}
+	c.mu.Lock()
+	m.failures++
+	if m.failures >= c.ejectAfter || errors.Is(err, ports.ErrPermanent) {
+		m.healthy = false
+	}
+	m.lastError = err.Error()
+	c.mu.Unlock()
+	return true
+}
+
+// acquire picks the healthy host with the lowest load per unit of weight,
+// preferring hosts below their concurrency limit, and counts the search
+// against it.
+func (c *Cluster) acquire(tried map[*member]bool) *member {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var best *member
+	for _, m := range c.members {
+		if tried[m] || !m.healthy {
+			continue
+		}
+		if best == nil || less(m, best) {
+			best = m
+		}
+	}
+	if best != nil {
+		tried[best] = true
+		best.inflight++
+	}
+	return best
+}
+
+func less(a, b *member) bool {
+	if af, bf := a.full(), b.full(); af != bf {
+		return bf
+	}
+	// a.inflight/a.Weight < b.inflight/b.Weight without division.
+	if l, r := a.inflight*b.Weight, b.inflight*a.Weight; l != r {
+		return l < r
+	}
+	return a.Weight > b.Weight
+}
+
+func (m *member) full() bool {
+	return m.MaxConcurrent > 0 && m.inflight >= m.MaxConcurrent
+}
+
+func (c *Cluster) release(m *member) {
+	c.mu.Lock()
+	m.inflight--
+	c.mu.Unlock()
+}
+
+func (c *Cluster) probeLoop() {
+	ticker := time.NewTicker(c.probeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.probe(context.Background())
+		}
+	}
+}
+
+// probe checks every host concurrently and records the outcome.
+func (c *Cluster) probe(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, m := range c.members {
+		wg.Add(1)
+		go func(m *member) {
+			defer wg.Done()
+			pctx := ctx
+			if c.probeTimeout > 0 {
+				var cancel context.CancelFunc
+				pctx, cancel = context.WithTimeout(ctx, c.probeTimeout)
+				defer cancel()
+			}
+			err := m.Backend.Health(pctx)
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			m.healthy = err == nil
+			m.lastError = ""
+			if err != nil {
+				m.lastError = err.Error()
+			} else {
+				m.failures = 0
+			}
+			m.checkedAt = time.Now()
+		}(m)
+	}
+	wg.Wait()
+}
+
+// MemberStats describes one host for /health and /metrics.
+type MemberStats struct {
+	Healthy       bool              `json:"healthy"`
+	Inflight      int               `json:"inflight"`
+	Weight        int               `json:"weight"`
+	MaxConcurrent int               `json:"maxConcurrent,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	LastError     string            `json:"lastError,omitempty"`
+	CheckedAt     *time.Time        `json:"checkedAt,omitempty"`
+}
+
+func (c *Cluster) Stats() map[string]MemberStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]MemberStats, len(c.members))
+	for _, m := range c.members {
+		s := MemberStats{
+			Healthy:       m.healthy,
+			Inflight:      m.inflight,
+			Weight:        m.Weight,
+			MaxConcurrent: m.MaxConcurrent,
+			Tags:          m.Tags,
+			LastError:     m.lastError,
+		}
+		if !m.checkedAt.IsZero() {
+			checked := m.checkedAt
+			s.CheckedAt = &checked
+		}
+		out[m.Name] = s
+	}
+	return out
+}
+
+type session struct {
+	ports.EngineSession
+	once    sync.Once
+	release func()
+}
+
+func (s *session) Close() error {
+	err := s.EngineSession.Close()
+	s.once.Do(s.release)
+	return err
+}
@@ -0,0 +1,210 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+type fakeHost struct {
+	name string
+
+	mu       sync.Mutex
+	down     bool
+	err      error
+	searches int
+	block    chan struct{}
+}
+
+func (h *fakeHost) Health(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.down {
+		return errors.New("dial tcp: connection refused")
+	}
+	return nil
+}
+
+func (h *fakeHost) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	h.mu.Lock()
+	h.searches++
+	down, herr, block := h.down, h.err, h.block
+	h.mu.Unlock()
+	if down {
+		return ports.AnalyzeResult{}, &ports.UnavailableError{Err: errors.New("engine exited")}
+	}
+	if herr != nil {
+		return ports.AnalyzeResult{}, herr
+	}
+	if block != nil {
+		<-block
+	}
+	return ports.AnalyzeResult{BestMoveUCI: "e2e4", PV: h.name}, nil
+}
+
+func (h *fakeHost) StartSession(ctx context.Context, req ports.AnalyzeRequest) (ports.EngineSession, error) {
+	return nil, errors.New("not supported")
+}
+
+func (h *fakeHost) setDown(down bool) {
+	h.mu.Lock()
+	h.down = down
+	h.mu.Unlock()
+}
+
+func TestCluster_FailsOverAndEjects(t *testing.T) {
+	a, b := &fakeHost{name: "a", down: true}, &fakeHost{name: "b"}
+	c := New([]Member{{Name: "a", Backend: a, Weight: 2}, {Name: "b", Backend: b}}, 1, 0, 0)
+	defer c.Close()
+
+	res, err := c.Analyze(context.Background(), ports.AnalyzeRequest{})
+	if err != nil || res.PV != "b" {
+		t.Fatalf("Analyze = %+v, %v; want failover to b", res, err)
+	}
+	if c.Stats()["a"].Healthy {
+		t.Error("failed host should be ejected")
+	}
+
+	c.Analyze(context.Background(), ports.AnalyzeRequest{})
+	if a.searches != 1 {
+		t.Errorf("ejected host got %d searches, want 1", a.searches)
+	}
+
+	a.setDown(false)
+	if err := c.Health(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Stats()["a"].Healthy {
+		t.Error("a successful probe should bring the host back")
+	}
+}
+
+func TestCluster_EjectsAfterConsecutiveFailures(t *testing.T) {
+	a, b := &fakeHost{name: "a", down: true}, &fakeHost{name: "b"}
+	c := New([]Member{{Name: "a", Backend: a, Weight: 2}, {Name: "b", Backend: b}}, 2, 0, 0)
+	defer c.Close()
+	ctx := context.Background()
+
+	analyze := func() {
+		t.Helper()
+		if res, err := c.Analyze(ctx, ports.AnalyzeRequest{}); err != nil || res.PV != "b" {
+			t.Fatalf("Analyze = %+v, %v; want failover to b", res, err)
+		}
+	}
+	analyze()
+	if !c.Stats()["a"].Healthy {
+		t.Fatal("one failed search should not eject a host")
+	}
+
+	// A success in between starts the count again.
+	a.setDown(false)
+	if _, err := c.Analyze(ctx, ports.AnalyzeRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	a.setDown(true)
+	analyze()
+	if !c.Stats()["a"].Healthy {
+		t.Fatal("failures separated by a success should not eject a host")
+	}
+	analyze()
+	if c.Stats()["a"].Healthy {
+		t.Error("consecutive failures should eject the host")
+	}
+}
+
+func TestCluster_FailsOverWhenOverloaded(t *testing.T) {
+	full := &ports.OverloadedError{QueueFull: true, RetryAfter: time.Second}
+	a, b := &fakeHost{name: "a", err: full}, &fakeHost{name: "b"}
+	c := New([]Member{{Name: "a", Backend: a, Weight: 2}, {Name: "b", Backend: b}}, 1, 0, 0)
+	defer c.Close()
+
+	res, err := c.Analyze(context.Background(), ports.AnalyzeRequest{})
+	if err != nil || res.PV != "b" {
+		t.Fatalf("Analyze = %+v, %v; want failover to b", res, err)
+	}
+	if !c.Stats()["a"].Healthy {
+		t.Error("a busy host should not be ejected")
+	}
+
+	b.mu.Lock()
+	b.err = full
+	b.mu.Unlock()
+	if _, err := c.Analyze(context.Background(), ports.AnalyzeRequest{}); !errors.Is(err, ports.ErrOverloaded) {
+		t.Errorf("every host busy: err = %v, want ErrOverloaded", err)
+	}
+}
+
+func TestCluster_AllHostsDown(t *testing.T) {
+	a := &fakeHost{name: "a", down: true}
+	c := New([]Member{{Name: "a", Backend: a}}, 1, 0, 0)
+	defer c.Close()
+
+	_, err := c.Analyze(context.Background(), ports.AnalyzeRequest{})
+	if !errors.Is(err, ports.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if err := c.Health(context.Background()); err == nil {
+		t.Error("Health should fail with every host down")
+	}
+}
+
+func TestCluster_LeastLoadedByWeight(t *testing.T) {
+	block := make(chan struct{})
+	a := &fakeHost{name: "a", block: block}
+	b := &fakeHost{name: "b", block: block}
+	c := New([]Member{{Name: "a", Backend: a, Weight: 2}, {Name: "b", Backend: b, Weight: 1}}, 1, 0, 0)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Analyze(context.Background(), ports.AnalyzeRequest{})
+		}()
+		// Let each search reach its host before routing the next one.
+		deadline := time.Now().Add(time.Second)
+		for total(c) != i+1 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	stats := c.Stats()
+	if stats["a"].Inflight != 2 || stats["b"].Inflight != 1 {
+		t.Errorf("inflight a=%d b=%d, want 2 and 1", stats["a"].Inflight, stats["b"].Inflight)
+	}
+	close(block)
+	wg.Wait()
+}
+
+func TestCluster_PrefersHostsWithCapacity(t *testing.T) {
+	block := make(chan struct{})
+	a := &fakeHost{name: "a", block: block}
+	b := &fakeHost{name: "b", block: block}
+	c := New([]Member{{Name: "a", Backend: a, Weight: 10, MaxConcurrent: 1}, {Name: "b", Backend: b}}, 1, 0, 0)
+	defer c.Close()
+
+	go c.Analyze(context.Background(), ports.AnalyzeRequest{})
+	for total(c) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	go c.Analyze(context.Background(), ports.AnalyzeRequest{})
+	for total(c) != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := c.Stats(); stats["b"].Inflight != 1 {
+		t.Errorf("second search should go to b once a is full, got %+v", stats)
+	}
+	close(block)
+}
+
+func total(c *Cluster) int {
+	n := 0
+	for _, s := range c.Stats() {
+		n += s.Inflight
+	}
+	return n
+}
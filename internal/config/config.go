@@ -2,23 +2,123 @@ package config
 
 import (
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	ServerPort    string
-	SSHHost       string
-	SSHPort       int
-	SSHUser       string
-	SSHPassword   string
-	SSHPrivateKey string
-	SSHTimeout    time.Duration
+	ServerPort             string
+	EngineBackend          string
+	SSHHost                string
+	SSHPort                int
+	SSHUser                string
+	SSHPassword            string
+	SSHPrivateKey          string
+	SSHTimeout             time.Duration
+	SSHHostKeyMode         string
+	SSHKnownHosts          string
+	SSHHostKeyFingerprints []string
+	SSHPoolSize            int
+	SSHIdleTimeout         time.Duration
+	SSHKeepAlive           time.Duration
+	EngineHosts            []EngineHost
+	ClusterProbeInterval   time.Duration
+	ClusterEjectAfter      int
+	StockfishPath          string
+	FakeEngineScript       string
+	FakeEngineDelay        time.Duration
+	EnginePoolSize         int
+	EngineMaxSearches      int
+	EngineReadyTimeout     time.Duration
+	EngineProfiles         map[string]map[string]string
+	EngineMaxConcurrent    int
+	EngineQueueSize        int
+	EngineQueueTimeout     time.Duration
+	EnginePriorityCaps     map[string]int
+	EngineStarvationAge    time.Duration
+	EngineRetries          int
+	EngineRetryBackoff     time.Duration
+	EngineRetryMaxBackoff  time.Duration
+	BreakerThreshold       int
+	BreakerOpenTimeout     time.Duration
+	AnalysisDepth          int
+	AnalysisTimeout        time.Duration
+	MaxDepth               int
+	MaxMoveTime            time.Duration
+	MaxNodes               int
+	MaxMate                int
+	MaxMultiPV             int
+	MaxHashMB              int
+	MaxThreads             int
+	MaxPlies               int
+	MaxGameAnalysisTime    time.Duration
+	ReviewTimeout          time.Duration
+	SessionIdleTimeout     time.Duration
+	MaxSessions            int
+	JobWorkers             int
+	JobQueueSize           int
+	JobRetention           time.Duration
+	CacheBackend           string
+	CacheSize              int
+	CacheDir               string
+	CacheDiskSize          int
+	CacheTTL               time.Duration
+	IncludeRaw             bool
+}
+
+// EngineHost is one machine of a multi-host cluster. Empty fields fall back
+// to the global SSH_* and STOCKFISH_PATH settings.
+type EngineHost struct {
+	Name          string
+	Host          string
+	Port          int
+	User          string
+	Password      string
+	PrivateKey    string
+	Fingerprints  []string
 	StockfishPath string
-	AnalysisDepth int
-	IncludeRaw    bool
+	Weight        int
+	MaxConcurrent int
+	Tags          map[string]string
+}
+
+// EngineSlots is how many engine processes a backend runs at once: the
+// ENGINE_POOL_SIZE kept for searches plus one per infinite session, so
+// that open sessions never take the processes searches rely on. Without
+// a session limit the two share the pool.
+func (c Config) EngineSlots() int {
+	return c.EnginePoolSize + max(c.MaxSessions, 0)
+}
+
+// ForHost returns the configuration used to reach h.
+func (c Config) ForHost(h EngineHost) Config {
+	hc := c
+	hc.SSHHost = h.Host
+	if h.Port != 0 {
+		hc.SSHPort = h.Port
+	}
+	if h.User != "" {
+		hc.SSHUser = h.User
+	}
+	if h.Password != "" || h.PrivateKey != "" {
+		hc.SSHPassword = h.Password
+		hc.SSHPrivateKey = h.PrivateKey
+	}
+	if len(h.Fingerprints) > 0 {
+		hc.SSHHostKeyFingerprints = h.Fingerprints
+	}
+	if h.StockfishPath != "" {
+		hc.StockfishPath = h.StockfishPath
+	}
+	if h.MaxConcurrent > 0 {
+		hc.EnginePoolSize = h.MaxConcurrent
+		hc.EngineMaxConcurrent = hc.EngineSlots()
+	}
+	return hc
 }
 
 func Load() Config {
@@ -28,16 +128,63 @@ func Load() Config {
 		serverPort = getEnv("SERVER_PORT", "8080")
 	}
 	return Config{
-		ServerPort:    serverPort,
-		SSHHost:       getEnv("SSH_HOST", ""),
-		SSHPort:       getEnvInt("SSH_PORT", 22),
-		SSHUser:       getEnv("SSH_USER", ""),
-		SSHPassword:   getEnv("SSH_PASSWORD", ""),
-		SSHPrivateKey: getEnv("SSH_PRIVATE_KEY", ""),
-		SSHTimeout:    getEnvDuration("SSH_TIMEOUT", 5*time.Second),
-		StockfishPath: getEnv("STOCKFISH_PATH", "stockfish"),
-		AnalysisDepth: getEnvInt("ANALYSIS_DEPTH", 12),
-		IncludeRaw:    getEnvBool("INCLUDE_RAW", false),
+		ServerPort:             serverPort,
+		EngineBackend:          getEnv("ENGINE_BACKEND", "ssh"),
+		SSHHost:                getEnv("SSH_HOST", ""),
+		SSHPort:                getEnvInt("SSH_PORT", 22),
+		SSHUser:                getEnv("SSH_USER", ""),
+		SSHPassword:            getEnv("SSH_PASSWORD", ""),
+		SSHPrivateKey:          getEnv("SSH_PRIVATE_KEY", ""),
+		SSHTimeout:             getEnvDuration("SSH_TIMEOUT", 5*time.Second),
+		SSHHostKeyMode:         getEnv("SSH_HOST_KEY_MODE", "strict"),
+		SSHKnownHosts:          getEnv("SSH_KNOWN_HOSTS", ""),
+		SSHHostKeyFingerprints: getEnvList("SSH_HOST_KEY_FINGERPRINTS"),
+		SSHPoolSize:            getEnvInt("SSH_POOL_SIZE", 4),
+		SSHIdleTimeout:         getEnvDuration("SSH_IDLE_TIMEOUT", 5*time.Minute),
+		SSHKeepAlive:           getEnvDuration("SSH_KEEPALIVE", 30*time.Second),
+		EngineHosts:            getEnvHosts("ENGINE_HOSTS"),
+		ClusterProbeInterval:   getEnvDuration("CLUSTER_PROBE_INTERVAL", 10*time.Second),
+		ClusterEjectAfter:      getEnvInt("CLUSTER_EJECT_AFTER", 3),
+		StockfishPath:          getEnv("STOCKFISH_PATH", "stockfish"),
+		FakeEngineScript:       getEnv("FAKE_ENGINE_SCRIPT", ""),
+		FakeEngineDelay:        getEnvDuration("FAKE_ENGINE_DELAY", 50*time.Millisecond),
+		EnginePoolSize:         getEnvInt("ENGINE_POOL_SIZE", 2),
+		EngineMaxSearches:      getEnvInt("ENGINE_MAX_SEARCHES", 200),
+		EngineReadyTimeout:     getEnvDuration("ENGINE_READY_TIMEOUT", 5*time.Second),
+		EngineProfiles:         getEnvProfiles("ENGINE_PROFILES"),
+		EngineMaxConcurrent:    getEnvInt("ENGINE_MAX_CONCURRENT", 0),
+		EngineQueueSize:        getEnvInt("ENGINE_QUEUE_SIZE", 16),
+		EngineQueueTimeout:     getEnvDuration("ENGINE_QUEUE_TIMEOUT", 10*time.Second),
+		EnginePriorityCaps:     getEnvIntMap("ENGINE_PRIORITY_CAPS"),
+		EngineStarvationAge:    getEnvDuration("ENGINE_STARVATION_AGE", 30*time.Second),
+		EngineRetries:          getEnvInt("ENGINE_RETRIES", 2),
+		EngineRetryBackoff:     getEnvDuration("ENGINE_RETRY_BACKOFF", 200*time.Millisecond),
+		EngineRetryMaxBackoff:  getEnvDuration("ENGINE_RETRY_MAX_BACKOFF", 2*time.Second),
+		BreakerThreshold:       getEnvInt("BREAKER_THRESHOLD", 5),
+		BreakerOpenTimeout:     getEnvDuration("BREAKER_OPEN_TIMEOUT", 30*time.Second),
+		AnalysisDepth:          getEnvInt("ANALYSIS_DEPTH", 12),
+		AnalysisTimeout:        getEnvDuration("ANALYSIS_TIMEOUT", 30*time.Second),
+		MaxDepth:               getEnvInt("MAX_DEPTH", 30),
+		MaxMoveTime:            getEnvDuration("MAX_MOVETIME", 10*time.Second),
+		MaxNodes:               getEnvInt("MAX_NODES", 100000000),
+		MaxMate:                getEnvInt("MAX_MATE", 15),
+		MaxMultiPV:             getEnvInt("MAX_MULTIPV", 5),
+		MaxHashMB:              getEnvInt("MAX_HASH_MB", 1024),
+		MaxThreads:             getEnvInt("MAX_THREADS", 4),
+		MaxPlies:               getEnvInt("MAX_PLIES", 300),
+		MaxGameAnalysisTime:    getEnvDuration("MAX_GAME_ANALYSIS_TIME", 5*time.Minute),
+		ReviewTimeout:          getEnvDuration("REVIEW_TIMEOUT", time.Minute),
+		SessionIdleTimeout:     getEnvDuration("SESSION_IDLE_TIMEOUT", 2*time.Minute),
+		MaxSessions:            getEnvInt("MAX_SESSIONS", 1),
+		JobWorkers:             getEnvInt("JOB_WORKERS", 2),
+		JobQueueSize:           getEnvInt("JOB_QUEUE_SIZE", 100),
+		JobRetention:           getEnvDuration("JOB_RETENTION", time.Hour),
+		CacheBackend:           getEnv("CACHE_BACKEND", "memory"),
+		CacheSize:              getEnvInt("CACHE_SIZE", 1024),
+		CacheDir:               getEnv("CACHE_DIR", ".cache/analysis"),
+		CacheDiskSize:          getEnvInt("CACHE_DISK_SIZE", 100000),
+		CacheTTL:               getEnvDuration("CACHE_TTL", 0),
+		IncludeRaw:             getEnvBool("INCLUDE_RAW", false),
 	}
 }
 
@@ -48,6 +195,99 @@ func getEnv(key, def string) string {
 	return def
 }
 
+func getEnvList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// getEnvProfiles parses "name:Key=Value,Key=Value;name2:..." into named
+// sets of engine options.
+func getEnvProfiles(key string) map[string]map[string]string {
+	profiles := map[string]map[string]string{}
+	for _, entry := range strings.Split(os.Getenv(key), ";") {
+		name, values, ok := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		opts := map[string]string{}
+		for _, kv := range strings.Split(values, ",") {
+			if k, v, ok := strings.Cut(kv, "="); ok && strings.TrimSpace(k) != "" {
+				opts[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+		profiles[name] = opts
+	}
+	return profiles
+}
+
+// getEnvIntMap parses "name=N,name2=M" into a map.
+func getEnvIntMap(key string) map[string]int {
+	out := map[string]int{}
+	for _, kv := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			out[strings.TrimSpace(k)] = n
+		}
+	}
+	return out
+}
+
+// getEnvHosts parses "name:host=H,port=P,user=U,key=K,weight=W,max=N,
+// tags=cpu:8|az:a;name2:..." into cluster hosts, ordered by name. password,
+// fingerprints (|-separated) and stockfish are accepted as well.
+func getEnvHosts(key string) []EngineHost {
+	profiles := getEnvProfiles(key)
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var hosts []EngineHost
+	for _, name := range names {
+		v := profiles[name]
+		if v["host"] == "" {
+			continue
+		}
+		h := EngineHost{
+			Name:          name,
+			Host:          v["host"],
+			User:          v["user"],
+			Password:      v["password"],
+			PrivateKey:    v["key"],
+			StockfishPath: v["stockfish"],
+			Weight:        1,
+			Tags:          map[string]string{},
+		}
+		h.Port, _ = strconv.Atoi(v["port"])
+		if w, err := strconv.Atoi(v["weight"]); err == nil && w > 0 {
+			h.Weight = w
+		}
+		h.MaxConcurrent, _ = strconv.Atoi(v["max"])
+		for _, fp := range strings.Split(v["fingerprints"], "|") {
+			if fp = strings.TrimSpace(fp); fp != "" {
+				h.Fingerprints = append(h.Fingerprints, fp)
+			}
+		}
+		for _, tag := range strings.Split(v["tags"], "|") {
+			if k, val, ok := strings.Cut(tag, ":"); ok {
+				h.Tags[strings.TrimSpace(k)] = strings.TrimSpace(val)
+			}
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
 func getEnvInt(key string, def int) int {
 	if v := os.Getenv(key); v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
@@ -0,0 +1,317 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/fakeengine"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+func testConfig() config.Config {
+	return config.Config{
+		EnginePoolSize:     1,
+		EngineMaxSearches:  100,
+		EngineReadyTimeout: time.Second,
+		AnalysisDepth:      3,
+		MaxDepth:           10,
+		MaxMultiPV:         3,
+	}
+}
+
+func newFakeEngine(t *testing.T, script string) *Engine {
+	t.Helper()
+	fake := fakeengine.New()
+	if script != "" {
+		rules, err := fakeengine.ParseScript(strings.NewReader(script))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fake.Rules = rules
+	}
+	e := New(fake.Spawn, testConfig())
+	t.Cleanup(e.Close)
+	return e
+}
+
+func TestAnalyze_FakeEngine(t *testing.T) {
+	e := newFakeEngine(t, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The fake engine prefers material, so it must take the hanging queen.
+	var updates int
+	result, err := e.Analyze(ctx, ports.AnalyzeRequest{
+		FEN:      "4k3/8/8/3q4/4P3/8/8/4K3 w - - 0 1",
+		Progress: func(ports.AnalysisUpdate) { updates++ },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BestMoveUCI != "e4d5" || result.BestMoveSAN != "exd5" {
+		t.Errorf("best move = %s (%s), want e4d5 (exd5)", result.BestMoveUCI, result.BestMoveSAN)
+	}
+	if result.Depth != 3 || updates != 3 {
+		t.Errorf("depth = %d, updates = %d, want 3 and 3", result.Depth, updates)
+	}
+	if result.EvaluationCp == nil || *result.EvaluationCp <= 0 {
+		t.Errorf("evaluation = %v, want White ahead", result.EvaluationCp)
+	}
+}
+
+func TestAnalyze_FinishedPositions(t *testing.T) {
+	e := newFakeEngine(t, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	mated, err := e.Analyze(ctx, ports.AnalyzeRequest{FEN: "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mated.EvaluationMate == nil || *mated.EvaluationMate != 0 || mated.EvalBar == nil || *mated.EvalBar != 0 {
+		t.Errorf("checkmate: mate = %v, bar = %v, want mate 0 with Black winning", mated.EvaluationMate, mated.EvalBar)
+	}
+
+	stalemate, err := e.Analyze(ctx, ports.AnalyzeRequest{FEN: "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stalemate.EvaluationCp == nil || *stalemate.EvaluationCp != 0 || stalemate.EvaluationMate != nil {
+		t.Errorf("stalemate: cp = %v, mate = %v, want 0 cp", stalemate.EvaluationCp, stalemate.EvaluationMate)
+	}
+}
+
+func TestAnalyze_CrashIsRecycled(t *testing.T) {
+	e := newFakeEngine(t, `
+> go
+< info depth 1 score cp 10 pv e2e4
+! crash
+! times 1
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := ports.AnalyzeRequest{UCIMoves: "e2e4"}
+
+	if _, err := e.Analyze(ctx, req); err == nil {
+		t.Fatal("expected an error from the crashed engine")
+	}
+	result, err := e.Analyze(ctx, req)
+	if err != nil {
+		t.Fatalf("second analysis should run on a fresh process: %v", err)
+	}
+	if result.BestMoveUCI == "" {
+		t.Error("no best move after recycling")
+	}
+}
+
+func TestAnalyze_MalformedOutput(t *testing.T) {
+	e := newFakeEngine(t, `
+> go
+< info depth banana score cp
+< info depth 4 score cp 25 nodes 10 pv e7e5 g1f3
+< garbage line
+< bestmove e7e5
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := e.Analyze(ctx, ports.AnalyzeRequest{UCIMoves: "e2e4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BestMoveSAN != "e5" || result.Depth != 4 || *result.EvaluationCp != -25 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestAnalyze_CancelStopsSearch(t *testing.T) {
+	e := newFakeEngine(t, `
+> go depth
+! hang
+! times 1
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	if _, err := e.Analyze(ctx, ports.AnalyzeRequest{UCIMoves: "e2e4"}); err == nil {
+		t.Fatal("expected a deadline error")
+	}
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel2()
+	if _, err := e.Analyze(ctx2, ports.AnalyzeRequest{UCIMoves: "e2e4"}); err != nil {
+		t.Fatalf("engine should be usable after a stopped search: %v", err)
+	}
+}
+
+func TestSession_Infinite(t *testing.T) {
+	e := newFakeEngine(t, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s, err := e.StartSession(ctx, ports.AnalyzeRequest{UCIMoves: "e2e4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if snap := s.Snapshot(); snap.BestMoveUCI == "" || snap.Depth == 0 {
+		t.Errorf("snapshot = %+v", snap)
+	}
+	if err := s.SetPosition(ctx, ports.AnalyzeRequest{UCIMoves: "e2e4 e7e5"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Analyze(ctx, ports.AnalyzeRequest{UCIMoves: "e2e4"}); err != nil {
+		t.Fatalf("engine should return to the pool after the session: %v", err)
+	}
+}
+
+func TestPing_DoesNotWaitForSearches(t *testing.T) {
+	e := newFakeEngine(t, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The session holds the only engine.
+	s, err := e.StartSession(ctx, ports.AnalyzeRequest{UCIMoves: "e2e4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pctx, pcancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer pcancel()
+	if err := e.Ping(pctx); err != nil {
+		t.Errorf("ping while busy = %v, want nil", err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Ping(ctx); err != nil {
+		t.Errorf("ping while idle = %v", err)
+	}
+}
+
+func TestAnalyze_PlyRange(t *testing.T) {
+	fake := fakeengine.New()
+	var spawns atomic.Int32
+	e := New(func(ctx context.Context) (io.ReadWriteCloser, error) {
+		spawns.Add(1)
+		return fake.Spawn(ctx)
+	}, testConfig())
+	t.Cleanup(e.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var plyUpdates []int
+	result, err := e.Analyze(ctx, ports.AnalyzeRequest{
+		SANMoves: "e4 d5 exd5 Qxd5",
+		Plies:    &ports.PlyRange{From: 2, To: -1},
+		Progress: func(u ports.AnalysisUpdate) { plyUpdates = append(plyUpdates, *u.Ply) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Plies) != 3 {
+		t.Fatalf("got %d plies, want 3", len(result.Plies))
+	}
+	for i, want := range []struct {
+		ply int
+		san string
+	}{{2, "d5"}, {3, "exd5"}, {4, "Qxd5"}} {
+		if p := result.Plies[i]; p.Ply != want.ply || p.MoveSAN != want.san || p.BestMoveUCI == "" {
+			t.Errorf("ply %d = %+v", i, p)
+		}
+	}
+	if result.PositionFEN != result.Plies[2].PositionFEN {
+		t.Errorf("top-level result is not the last ply")
+	}
+	if len(plyUpdates) == 0 || plyUpdates[0] != 2 || plyUpdates[len(plyUpdates)-1] != 4 {
+		t.Errorf("progress plies = %v", plyUpdates)
+	}
+	if n := spawns.Load(); n != 1 {
+		t.Errorf("spawned %d engines, want 1", n)
+	}
+
+	// A single ply analyzes that position only.
+	single, err := e.Analyze(ctx, ports.AnalyzeRequest{SANMoves: "e4 d5 exd5 Qxd5", Plies: &ports.PlyRange{From: 3, To: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if single.Plies != nil || single.BestMoveSAN != "Qxd5" {
+		t.Errorf("single ply = %+v", single)
+	}
+
+	_, err = e.Analyze(ctx, ports.AnalyzeRequest{SANMoves: "e4 d5", Plies: &ports.PlyRange{From: 1, To: 5}})
+	if !errors.Is(err, ports.ErrInvalidInput) {
+		t.Errorf("out of range plies: err = %v", err)
+	}
+}
+
+// bestmove follows the only info line, so the forwarder decodes its PV
+// while the result is built; run with -race.
+func TestAnalyze_ProgressBeforeResult(t *testing.T) {
+	e := newFakeEngine(t, `
+> go
+< info depth 1 score cp 20 pv e7e5 g1f3 b8c6 f1b5
+< bestmove e7e5
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for range 20 {
+		var updates atomic.Int32
+		result, err := e.Analyze(ctx, ports.AnalyzeRequest{
+			UCIMoves: "e2e4",
+			Progress: func(ports.AnalysisUpdate) { updates.Add(1) },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.BestMoveUCI != "e7e5" || updates.Load() != 1 {
+			t.Errorf("result = %+v, %d updates", result, updates.Load())
+		}
+	}
+}
+
+func TestAnalyze_GameTimeLimit(t *testing.T) {
+	fake := fakeengine.New()
+	fake.SearchDelay = 50 * time.Millisecond
+	cfg := testConfig()
+	cfg.MaxMoveTime = 10 * time.Second
+	cfg.MaxGameAnalysisTime = 300 * time.Millisecond
+	e := New(fake.Spawn, cfg)
+	t.Cleanup(e.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	moves := "e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6"
+
+	// Eight plies at a fixed second each cannot fit in the game budget.
+	_, err := e.Analyze(ctx, ports.AnalyzeRequest{SANMoves: moves, Plies: &ports.PlyRange{From: 1, To: 8}, Limits: ports.SearchLimits{MoveTime: 1000}})
+	if !errors.Is(err, ports.ErrInvalidInput) {
+		t.Errorf("movetime over budget: err = %v", err)
+	}
+
+	// Each search takes 150ms, so the game runs out of time.
+	start := time.Now()
+	_, err = e.Analyze(ctx, ports.AnalyzeRequest{SANMoves: moves, Plies: &ports.PlyRange{From: 1, To: 8}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("slow game: err = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("analysis ran %v past its budget", elapsed)
+	}
+
+	// A request's own budget tightens the server's limit.
+	e.cfg.MaxGameAnalysisTime = time.Minute
+	_, err = e.Analyze(ctx, ports.AnalyzeRequest{SANMoves: moves, Plies: &ports.PlyRange{From: 1, To: 8}, TimeBudget: 300 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("slow game with a budget: err = %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"github.com/aminammar1/stockfish-go-ec2/internal/uci"
+	"github.com/notnil/chess"
+)
+
+// Engine runs analyses on pooled Stockfish processes. Adapters supply the
+// SpawnFunc that decides where the processes actually run.
+type Engine struct {
+	cfg  config.Config
+	pool *Pool
+}
+
+func New(spawn SpawnFunc, cfg config.Config) *Engine {
+	return &Engine{
+		cfg:  cfg,
+		pool: NewPool(spawn, cfg.EngineSlots(), cfg.EngineMaxSearches, cfg.EngineReadyTimeout, cfg.IncludeRaw),
+	}
+}
+
+func (e *Engine) Close() {
+	e.pool.Close()
+}
+
+// Ping checks that an idle or new process answers isready, without
+// queueing behind searches.
+func (e *Engine) Ping(ctx context.Context) error {
+	return e.pool.Ping(ctx)
+}
+
+func (e *Engine) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	game, err := ParseGame(req)
+	if err != nil {
+		return ports.AnalyzeResult{}, err
+	}
+	if err := e.checkPlies(game, req); err != nil {
+		return ports.AnalyzeResult{}, err
+	}
+
+	proc, err := e.pool.Lease(ctx)
+	if err != nil {
+		return ports.AnalyzeResult{}, unavailable(ctx, err)
+	}
+	broken := true
+	defer func() { e.pool.Release(proc, broken) }()
+
+	options, err := e.resolveOptions(proc.client.Options(), req.Profile, req.Options)
+	if err != nil {
+		broken = false
+		return ports.AnalyzeResult{}, err
+	}
+	if err := e.applyOptions(ctx, proc, options); err != nil {
+		return ports.AnalyzeResult{}, unavailable(ctx, err)
+	}
+	if err := e.setMultiPV(ctx, proc, req.MultiPV); err != nil {
+		return ports.AnalyzeResult{}, unavailable(ctx, err)
+	}
+
+	if game.Selected() == 1 {
+		result, reusable, err := e.search(ctx, proc, game, req, e.cfg.AnalysisTimeout, req.Progress)
+		if err != nil {
+			broken = !reusable
+			return ports.AnalyzeResult{}, err
+		}
+		broken = false
+		result.Options = options
+		return result, nil
+	}
+
+	// Every ply runs on the same process: each position command extends the
+	// previous one, so the hash table carries over from ply to ply. The
+	// game as a whole gets its time limit, or less if the caller's
+	// deadline is earlier, and each ply stops after an even share of what
+	// is left, keeping a margin so the last one still returns in time.
+	if limit := e.gameTimeLimit(req); limit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limit)
+		defer cancel()
+	}
+	deadline, hasDeadline := ctx.Deadline()
+	var plies []ports.PlyResult
+	for ply := game.First; ply <= len(game.Moves); ply++ {
+		var progress func(ports.AnalysisUpdate)
+		if req.Progress != nil {
+			progress = func(u ports.AnalysisUpdate) {
+				u.Ply = &ply
+				req.Progress(u)
+			}
+		}
+		budget := e.cfg.AnalysisTimeout
+		if hasDeadline {
+			share := time.Until(deadline) * 9 / 10 / time.Duration(len(game.Moves)-ply+1)
+			if budget <= 0 || share < budget {
+				budget = max(share, time.Millisecond)
+			}
+		}
+		result, reusable, err := e.search(ctx, proc, game.At(ply), req, budget, progress)
+		if err != nil {
+			broken = !reusable
+			return ports.AnalyzeResult{}, err
+		}
+		entry := ports.PlyResult{Ply: ply, AnalyzeResult: result}
+		if ply > 0 {
+			before, move := game.positions[ply-1], game.Moves[ply-1]
+			entry.MoveUCI = chess.UCINotation{}.Encode(before, move)
+			entry.MoveSAN = chess.AlgebraicNotation{}.Encode(before, move)
+		}
+		plies = append(plies, entry)
+	}
+	broken = false
+
+	result := plies[len(plies)-1].AnalyzeResult
+	result.Options = options
+	result.Plies = plies
+	return result, nil
+}
+
+// gameTimeLimit is the total time a multi-ply analysis may take:
+// MaxGameAnalysisTime, or the request's budget if smaller. 0 is no limit.
+func (e *Engine) gameTimeLimit(req ports.AnalyzeRequest) time.Duration {
+	limit := e.cfg.MaxGameAnalysisTime
+	if req.TimeBudget > 0 && (limit <= 0 || req.TimeBudget < limit) {
+		limit = req.TimeBudget
+	}
+	return limit
+}
+
+// checkPlies bounds multi-ply requests: at most MaxPlies positions, and
+// no more fixed search time than the game's time limit allows.
+func (e *Engine) checkPlies(game *Game, req ports.AnalyzeRequest) error {
+	n := game.Selected()
+	if n <= 1 {
+		return nil
+	}
+	if e.cfg.MaxPlies > 0 && n > e.cfg.MaxPlies {
+		return &ports.InputError{Field: "ply", Err: fmt.Errorf("%d plies selected, at most %d allowed", n, e.cfg.MaxPlies)}
+	}
+	moveTime := time.Duration(ClampLimits(req.Limits, e.cfg, game.Final().Turn()).MoveTime) * time.Millisecond
+	if total, limit := time.Duration(n)*moveTime, e.gameTimeLimit(req); limit > 0 && total > limit {
+		return &ports.InputError{Field: "ply", Err: fmt.Errorf("%d plies at movetime %v need %v, more than the %v allowed", n, moveTime, total, limit)}
+	}
+	return nil
+}
+
+// search analyzes the final position of game on proc, stopping the search
+// after budget if it is positive. On failure it reports whether proc is
+// still fit for reuse.
+func (e *Engine) search(ctx context.Context, proc *Process, game *Game, req ports.AnalyzeRequest, budget time.Duration, progress func(ports.AnalysisUpdate)) (ports.AnalyzeResult, bool, error) {
+	pos := game.Final()
+	if err := e.loadPosition(ctx, proc, game.Command()); err != nil {
+		return ports.AnalyzeResult{}, false, unavailable(ctx, err)
+	}
+	search, err := proc.client.Go(goArgs(ClampLimits(req.Limits, e.cfg, pos.Turn())))
+	if err != nil {
+		return ports.AnalyzeResult{}, false, unavailable(ctx, err)
+	}
+	stopForward := func() {}
+	if progress != nil {
+		stopForward = forwardProgress(search, pos, progress)
+		defer stopForward()
+	}
+	// Past the server-side budget the search is stopped and whatever the
+	// engine found so far is returned.
+	if budget > 0 {
+		timer := time.AfterFunc(budget, func() { search.Stop() })
+		defer timer.Stop()
+	}
+
+	best, err := search.Wait(ctx)
+	if err != nil {
+		reusable := ctx.Err() != nil && e.stop(search)
+		return ports.AnalyzeResult{}, reusable, unavailable(ctx, err)
+	}
+	// The forwarder decodes PVs on pos too, and chess.Position caches its
+	// valid moves, so it must be done before the result is built.
+	stopForward()
+
+	result := buildResult(best, search.Latest(), search.Raw(), pos, e.cfg.IncludeRaw)
+	result.Draw = game.Draw()
+	return result, true, nil
+}
+
+// loadPosition sends posCmd, starting a new game first unless the position
+// continues the previous one, so the hash table survives within a game.
+func (e *Engine) loadPosition(ctx context.Context, proc *Process, posCmd string) error {
+	if proc.lastPos == "" || (posCmd != proc.lastPos && !strings.HasPrefix(posCmd, proc.lastPos+" ")) {
+		if err := proc.client.Send("ucinewgame"); err != nil {
+			return err
+		}
+		rctx, cancel := context.WithTimeout(ctx, e.cfg.EngineReadyTimeout)
+		err := proc.client.IsReady(rctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	proc.lastPos = posCmd
+	return proc.client.Send(posCmd)
+}
+
+// setMultiPV switches the number of reported lines, touching the engine
+// only when the value differs from what the process already uses.
+func (e *Engine) setMultiPV(ctx context.Context, proc *Process, n int) error {
+	n = clampMultiPV(n, e.cfg)
+	if n == proc.multiPV {
+		return nil
+	}
+	if err := proc.client.Send("setoption name MultiPV value " + strconv.Itoa(n)); err != nil {
+		return err
+	}
+	rctx, cancel := context.WithTimeout(ctx, e.cfg.EngineReadyTimeout)
+	defer cancel()
+	if err := proc.client.IsReady(rctx); err != nil {
+		return err
+	}
+	proc.multiPV = n
+	return nil
+}
+
+// stop ends a search whose caller gave up and reports whether the engine
+// answered with bestmove, i.e. whether the process can be reused.
+func (e *Engine) stop(search *uci.Search) bool {
+	if err := search.Stop(); err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.EngineReadyTimeout)
+	defer cancel()
+	_, err := search.Wait(ctx)
+	return err == nil
+}
+
+// unavailable marks err as a failure of the engine rather than of the
+// request, unless the caller gave up.
+func unavailable(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	return &ports.UnavailableError{Err: err}
+}
@@ -0,0 +1,300 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/pgn"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"github.com/aminammar1/stockfish-go-ec2/internal/uci"
+	"github.com/notnil/chess"
+)
+
+func TestBuildPositionCommand_FEN(t *testing.T) {
+	tests := []struct {
+		name    string
+		fen     string
+		wantErr bool
+	}{
+		{"starting position", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", false},
+		{"after e4", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", false},
+		{"invalid fen", "invalid", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := ports.AnalyzeRequest{FEN: tt.fen}
+			_, _, err := BuildPosition(req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BuildPosition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPosition_TypedErrors(t *testing.T) {
+	_, _, err := BuildPosition(ports.AnalyzeRequest{UCIMoves: "e2e4 e7e5 e1e3"})
+	var illegal *ports.IllegalMoveError
+	if !errors.As(err, &illegal) {
+		t.Fatalf("want IllegalMoveError, got %v", err)
+	}
+	if illegal.Index != 2 || illegal.Move != "e1e3" || illegal.FEN == "" {
+		t.Errorf("unexpected details: %+v", illegal)
+	}
+
+	for _, req := range []ports.AnalyzeRequest{
+		{FEN: "invalid"},
+		{UCIMoves: "e2e4 zz"},
+		{SANMoves: "e4 e5??!x"},
+		{},
+	} {
+		if _, _, err := BuildPosition(req); !errors.Is(err, ports.ErrInvalidInput) {
+			t.Errorf("%+v: want invalid input, got %v", req, err)
+		}
+	}
+}
+
+func TestParseGame_History(t *testing.T) {
+	game, err := ParseGame(ports.AnalyzeRequest{PGN: "1. Nf3 Nf6 2. Ng1 Ng8 3. Nf3 Nf6 4. Ng1 Ng8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "position startpos moves g1f3 g8f6 f3g1 f6g8 g1f3 g8f6 f3g1 f6g8"; game.Command() != want {
+		t.Errorf("command = %q, want %q", game.Command(), want)
+	}
+	if d := game.Draw(); d.Repetitions != 3 || !d.ThreefoldRepetition || d.FiftyMoveRule {
+		t.Errorf("draw = %+v", d)
+	}
+
+	// The first Black-to-move position follows a double push, but without
+	// a pawn to take en passant it repeats all the same.
+	game, err = ParseGame(ports.AnalyzeRequest{PGN: "1. e4 Nf6 2. Nf3 Ng8 3. Ng1 Nf6 4. Nf3 Ng8 5. Ng1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := game.Draw(); d.Repetitions != 3 || !d.ThreefoldRepetition {
+		t.Errorf("after 1. e4: draw = %+v", d)
+	}
+
+	// A capture en passant available once makes that position different.
+	game, err = ParseGame(ports.AnalyzeRequest{FEN: "4k3/8/8/8/5p2/8/4P3/4K2N w - - 0 1", SANMoves: "e4 Kd7 Ng3 Ke8 Nh1 Kd7 Ng3 Ke8 Nh1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := game.Draw(); d.Repetitions != 2 || d.ThreefoldRepetition {
+		t.Errorf("after a legal en passant: draw = %+v", d)
+	}
+
+	game, err = ParseGame(ports.AnalyzeRequest{FEN: "8/8/8/4k3/8/8/4K3/7R w - - 99 80", UCIMoves: "h1h2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := game.Draw(); d.HalfmoveClock != 100 || !d.FiftyMoveRule || d.Repetitions != 1 {
+		t.Errorf("draw = %+v", d)
+	}
+
+	// The same position reached through repetitions is a different search.
+	cfg := config.Config{}
+	fresh, _ := NewSearchKey(ports.AnalyzeRequest{FEN: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 8 5"}, cfg)
+	repeated, _ := NewSearchKey(ports.AnalyzeRequest{UCIMoves: "g1f3 g8f6 f3g1 f6g8 g1f3 g8f6 f3g1 f6g8"}, cfg)
+	if fresh.Key == repeated.Key {
+		t.Error("repetition history ignored by search key")
+	}
+}
+
+// FuzzBuildPosition checks that whatever a client sends, the position
+// command is a single well-formed line built from parsed values.
+func FuzzBuildPosition(f *testing.F) {
+	f.Add("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2e4 e7e5", "", "")
+	f.Add("", "e2e4\nsetoption name Threads value 512", "", "")
+	f.Add("", "e2e4\ne7e5", "", "")
+	f.Add("8/8/8/8/8/8/8/K6k w - - 0 1\nsetoption name Hash value 1", "", "", "")
+	f.Add("8/8/8/8/8/8/8/K6k w - - 0 1", "", "Kb2\r\nquit", "")
+	f.Add("", "", "", "[Event \"x\"]\n1. e4 e5 {\nquit\n} 2. Nf3")
+
+	f.Fuzz(func(t *testing.T, fen, uciMoves, sanMoves, pgnText string) {
+		cmd, _, err := BuildPosition(ports.AnalyzeRequest{FEN: fen, UCIMoves: uciMoves, SANMoves: sanMoves, PGN: pgnText})
+		if err != nil {
+			return
+		}
+
+		var out bytes.Buffer
+		if err := uci.NewClient(&out, strings.NewReader("")).Send(cmd); err != nil {
+			t.Fatalf("send %q: %v", cmd, err)
+		}
+		if n := strings.Count(out.String(), "\n"); n != 1 {
+			t.Fatalf("%q wrote %d lines", cmd, n)
+		}
+
+		fields := strings.Fields(cmd)
+		if len(fields) < 2 || fields[0] != "position" {
+			t.Fatalf("malformed command %q", cmd)
+		}
+		rest := fields[2:]
+		switch fields[1] {
+		case "startpos":
+		case "fen":
+			if len(rest) < 6 {
+				t.Fatalf("short fen in %q", cmd)
+			}
+			if _, err := pgn.ParseFEN(strings.Join(rest[:6], " ")); err != nil {
+				t.Fatalf("bad fen in %q: %v", cmd, err)
+			}
+			rest = rest[6:]
+		default:
+			t.Fatalf("malformed command %q", cmd)
+		}
+		if len(rest) == 0 {
+			return
+		}
+		if rest[0] != "moves" || len(rest) == 1 {
+			t.Fatalf("malformed move list in %q", cmd)
+		}
+		for _, m := range rest[1:] {
+			if !uciMovePattern.MatchString(m) {
+				t.Fatalf("unexpected token %q in %q", m, cmd)
+			}
+		}
+	})
+}
+
+func TestComputeEvalBar(t *testing.T) {
+	tests := []struct {
+		name    string
+		cp      *int
+		mate    *int
+		wantMin int
+		wantMax int
+	}{
+		{"equal position", intPtr(0), nil, 48, 52},
+		{"white advantage +100cp", intPtr(100), nil, 55, 65},
+		{"black advantage -100cp", intPtr(-100), nil, 35, 45},
+		{"mate for white", nil, intPtr(5), 100, 100},
+		{"mate for black", nil, intPtr(-5), 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bar := computeEvalBar(tt.cp, tt.mate)
+			if bar == nil {
+				t.Error("computeEvalBar() returned nil")
+				return
+			}
+			if *bar < tt.wantMin || *bar > tt.wantMax {
+				t.Errorf("computeEvalBar() = %d, want between %d and %d", *bar, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestClampLimits(t *testing.T) {
+	cfg := config.Config{AnalysisDepth: 12, MaxDepth: 30, MaxMoveTime: 5 * time.Second, MaxNodes: 1000, MaxMate: 10}
+
+	tests := []struct {
+		name   string
+		limits ports.SearchLimits
+		turn   chess.Color
+		want   string
+	}{
+		{"default depth", ports.SearchLimits{}, chess.White, "depth 12"},
+		{"depth capped", ports.SearchLimits{Depth: 99}, chess.White, "depth 30"},
+		{"movetime capped", ports.SearchLimits{MoveTime: 60000}, chess.White, "movetime 5000"},
+		{"nodes and mate", ports.SearchLimits{Nodes: 5000, Mate: 3}, chess.White, "nodes 1000 mate 3"},
+		{"mate alone", ports.SearchLimits{Mate: 3}, chess.White, "depth 12 mate 3"},
+		{"clock", ports.SearchLimits{WTime: 600000, BTime: 1000, WInc: 2000, MovesToGo: 40}, chess.White, "wtime 5000 btime 1000 winc 2000 movestogo 40"},
+		{"clock of the other side", ports.SearchLimits{WTime: 60000, WInc: 1000}, chess.Black, "depth 12 wtime 5000 winc 1000"},
+		{"clock with depth", ports.SearchLimits{Depth: 99, BTime: 1000}, chess.Black, "depth 30 btime 1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := goArgs(ClampLimits(tt.limits, cfg, tt.turn)); got != tt.want {
+				t.Errorf("goArgs(ClampLimits()) = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildResult_MultiPV(t *testing.T) {
+	// Black to move after 1. e4: engine scores are from Black's side.
+	_, pos, err := BuildPosition(ports.AnalyzeRequest{UCIMoves: "e2e4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []uci.Info{
+		{MultiPV: 1, Depth: 18, Score: &uci.Score{Cp: intPtr(-30)}, PV: []string{"c7c5", "g1f3"}},
+		{MultiPV: 2, Depth: 18, Score: &uci.Score{Mate: intPtr(-4)}, PV: []string{"f7f6", "d1h5"}},
+	}
+
+	result := buildResult(uci.BestMove{Move: "c7c5"}, lines, nil, pos, false)
+	if result.BestMoveSAN != "c5" || len(result.Lines) != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+	if got := *result.Lines[0].EvaluationCp; got != 30 {
+		t.Errorf("line 1 cp = %d, want 30 from White's perspective", got)
+	}
+	if got := *result.Lines[1].EvaluationMate; got != 4 {
+		t.Errorf("line 2 mate = %d, want 4 from White's perspective", got)
+	}
+	if got := result.Lines[1].MovesSAN; len(got) != 2 || got[0] != "f6" || got[1] != "Qh5+" {
+		t.Errorf("line 2 SAN = %v", got)
+	}
+	if result.EvaluationCp == nil || *result.EvaluationCp != 30 || result.PV != "c7c5 g1f3" {
+		t.Errorf("top-level fields should mirror line 1: %+v", result)
+	}
+}
+
+func TestResolveOptions(t *testing.T) {
+	advertised := []uci.Option{
+		uci.ParseOption("option name Hash type spin default 16 min 1 max 33554432"),
+		uci.ParseOption("option name Threads type spin default 1 min 1 max 1024"),
+		uci.ParseOption("option name UCI_LimitStrength type check default false"),
+		uci.ParseOption("option name Clear Hash type button"),
+	}
+	e := &Engine{cfg: config.Config{MaxHashMB: 2048, MaxThreads: 8, EngineProfiles: map[string]map[string]string{
+		"default": {"Hash": "64"},
+		"deep":    {"Threads": "4", "Hash": "1024"},
+		"huge":    {"Hash": "4096"},
+	}}}
+
+	got, err := e.resolveOptions(advertised, "deep", map[string]string{"threads": "2", "uci_limitstrength": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Hash": "1024", "Threads": "2", "UCI_LimitStrength": "true"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	for name, opts := range map[string]map[string]string{
+		"not whitelisted": {"Clear Hash": ""},
+		"out of range":    {"Threads": "4096"},
+		"over the cap":    {"Hash": "1000000"},
+		"threads cap":     {"Threads": "16"},
+		"not a number":    {"Hash": "lots"},
+		"unsupported":     {"Skill Level": "5"},
+	} {
+		if _, err := e.resolveOptions(advertised, "", opts); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := e.resolveOptions(advertised, "missing", nil); err == nil {
+		t.Error("unknown profile: expected error")
+	}
+	if _, err := e.resolveOptions(advertised, "", map[string]string{"Hash": "1000000"}); !errors.Is(err, ports.ErrInvalidInput) {
+		t.Errorf("hash over the cap: err = %v, want invalid input", err)
+	}
+	if _, err := e.resolveOptions(advertised, "huge", nil); !errors.Is(err, ports.ErrInvalidInput) {
+		t.Errorf("profile over the cap: err = %v, want invalid input", err)
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,30 @@
+package engine
+
+import "math"
+
+func computeEvalBar(cp *int, mate *int) *int {
+	if mate != nil {
+		if *mate > 0 {
+			v := 100
+			return &v
+		}
+		if *mate < 0 {
+			v := 0
+			return &v
+		}
+		v := 50
+		return &v
+	}
+	if cp == nil {
+		return nil
+	}
+	value := 50 + 50*math.Tanh(float64(*cp)/400.0)
+	bar := int(math.Round(value))
+	if bar < 0 {
+		bar = 0
+	}
+	if bar > 100 {
+		bar = 100
+	}
+	return &bar
+}
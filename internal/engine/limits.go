@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"github.com/notnil/chess"
+)
+
+// ClampLimits caps the requested limits at the configured maximums so no
+// request can keep an engine busy indefinitely. Unless movetime, nodes or
+// the clock of the side to move bounds the search, it is also limited by
+// depth, the default ANALYSIS_DEPTH when none was requested.
+func ClampLimits(l ports.SearchLimits, cfg config.Config, turn chess.Color) ports.SearchLimits {
+	clock := l.WTime
+	if turn == chess.Black {
+		clock = l.BTime
+	}
+	if l.MoveTime <= 0 && l.Nodes <= 0 && clock <= 0 && l.Depth <= 0 {
+		l.Depth = cfg.AnalysisDepth
+	}
+	maxTime := int(cfg.MaxMoveTime.Milliseconds())
+
+	l.Depth = clamp(l.Depth, cfg.MaxDepth)
+	l.Nodes = clamp(l.Nodes, cfg.MaxNodes)
+	l.Mate = clamp(l.Mate, cfg.MaxMate)
+	l.MoveTime = clamp(l.MoveTime, maxTime)
+	// Stockfish never spends more than the remaining clock, so capping the
+	// clocks bounds clock-style searches as well.
+	l.WTime = clamp(l.WTime, maxTime)
+	l.BTime = clamp(l.BTime, maxTime)
+	l.WInc = clamp(l.WInc, maxTime)
+	l.BInc = clamp(l.BInc, maxTime)
+	return l
+}
+
+// clampMultiPV returns the number of lines a search actually reports.
+func clampMultiPV(n int, cfg config.Config) int {
+	if n < 1 {
+		n = 1
+	}
+	if cfg.MaxMultiPV > 0 && n > cfg.MaxMultiPV {
+		n = cfg.MaxMultiPV
+	}
+	return n
+}
+
+func clamp(v, max int) int {
+	if v < 0 {
+		return 0
+	}
+	if max > 0 && v > max {
+		return max
+	}
+	return v
+}
+
+// goArgs renders limits as arguments of the UCI "go" command.
+func goArgs(l ports.SearchLimits) string {
+	var parts []string
+	add := func(name string, v int) {
+		if v > 0 {
+			parts = append(parts, name, strconv.Itoa(v))
+		}
+	}
+	add("depth", l.Depth)
+	add("movetime", l.MoveTime)
+	add("nodes", l.Nodes)
+	add("mate", l.Mate)
+	add("wtime", l.WTime)
+	add("btime", l.BTime)
+	add("winc", l.WInc)
+	add("binc", l.BInc)
+	add("movestogo", l.MovesToGo)
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"github.com/aminammar1/stockfish-go-ec2/internal/uci"
+)
+
+// tunableOptions are the engine options clients and profiles may change.
+var tunableOptions = []string{"Hash", "Threads", "Skill Level", "UCI_LimitStrength", "UCI_Elo", "Move Overhead"}
+
+func canonicalOption(name string) (string, bool) {
+	for _, o := range tunableOptions {
+		if strings.EqualFold(o, strings.TrimSpace(name)) {
+			return o, true
+		}
+	}
+	return "", false
+}
+
+// defaultOptions returns the advertised defaults of the tunable options.
+func defaultOptions(advertised []uci.Option) map[string]string {
+	out := map[string]string{}
+	for _, opt := range advertised {
+		if name, ok := canonicalOption(opt.Name); ok {
+			out[name] = opt.Default
+		}
+	}
+	return out
+}
+
+// resolveOptions layers the "default" profile, the named profile and the
+// request's own options over the engine defaults, validating each value
+// against what the engine advertised during the uci handshake.
+func (e *Engine) resolveOptions(advertised []uci.Option, profile string, requested map[string]string) (map[string]string, error) {
+	byName := map[string]uci.Option{}
+	for _, opt := range advertised {
+		if name, ok := canonicalOption(opt.Name); ok {
+			byName[name] = opt
+		}
+	}
+
+	desired := defaultOptions(advertised)
+	apply := func(values map[string]string) error {
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			name, ok := canonicalOption(k)
+			if !ok {
+				return fmt.Errorf("option %q is not adjustable (allowed: %s)", k, strings.Join(tunableOptions, ", "))
+			}
+			opt, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("engine does not support option %q", name)
+			}
+			value, err := validateOptionValue(opt, values[k], e.optionCap(name))
+			if err != nil {
+				return err
+			}
+			desired[name] = value
+		}
+		return nil
+	}
+
+	if err := apply(e.cfg.EngineProfiles["default"]); err != nil {
+		return nil, &ports.InputError{Field: "profile", Err: fmt.Errorf("default: %w", err)}
+	}
+	if profile != "" {
+		values, ok := e.cfg.EngineProfiles[profile]
+		if !ok {
+			return nil, &ports.InputError{Field: "profile", Err: fmt.Errorf("unknown engine profile %q", profile)}
+		}
+		if err := apply(values); err != nil {
+			return nil, &ports.InputError{Field: "profile", Err: fmt.Errorf("%s: %w", profile, err)}
+		}
+	}
+	if err := apply(requested); err != nil {
+		return nil, &ports.InputError{Field: "options", Err: err}
+	}
+	return desired, nil
+}
+
+// optionCap returns the server's own upper bound on a spin option, or 0.
+// Stockfish accepts Hash and Threads far beyond what the host can spare.
+func (e *Engine) optionCap(name string) int {
+	switch name {
+	case "Hash":
+		return e.cfg.MaxHashMB
+	case "Threads":
+		return e.cfg.MaxThreads
+	}
+	return 0
+}
+
+// validateOptionValue checks value against the server limit, if positive,
+// and then against the bounds the engine advertised.
+func validateOptionValue(opt uci.Option, value string, limit int) (string, error) {
+	value = strings.TrimSpace(value)
+	switch opt.Type {
+	case "spin":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("option %q expects an integer, got %q", opt.Name, value)
+		}
+		if limit > 0 && n > limit {
+			return "", fmt.Errorf("option %q must be at most %d on this server", opt.Name, limit)
+		}
+		if (opt.Min != nil && n < *opt.Min) || (opt.Max != nil && n > *opt.Max) {
+			return "", fmt.Errorf("option %q must be between %d and %d", opt.Name, deref(opt.Min), deref(opt.Max))
+		}
+		return strconv.Itoa(n), nil
+	case "check":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("option %q expects true or false, got %q", opt.Name, value)
+		}
+		return strconv.FormatBool(b), nil
+	default:
+		return "", fmt.Errorf("option %q of type %s cannot be set", opt.Name, opt.Type)
+	}
+}
+
+func deref(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+// applyOptions sends setoption for every value that differs from what the
+// process currently uses.
+func (e *Engine) applyOptions(ctx context.Context, proc *Process, desired map[string]string) error {
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := false
+	for _, name := range names {
+		if proc.options[name] == desired[name] {
+			continue
+		}
+		if err := proc.client.Send(fmt.Sprintf("setoption name %s value %s", name, desired[name])); err != nil {
+			return err
+		}
+		proc.options[name] = desired[name]
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	rctx, cancel := context.WithTimeout(ctx, e.cfg.EngineReadyTimeout)
+	defer cancel()
+	return proc.client.IsReady(rctx)
+}
@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"github.com/aminammar1/stockfish-go-ec2/internal/uci"
+)
+
+// SpawnFunc starts a new Stockfish process. Writes go to the engine's stdin,
+// reads come from its stdout, and Close terminates the process.
+type SpawnFunc func(ctx context.Context) (io.ReadWriteCloser, error)
+
+// Process is a long-lived Stockfish process driven through a UCI client.
+type Process struct {
+	conn     io.ReadWriteCloser
+	client   *uci.Client
+	searches int
+	lastPos  string
+	multiPV  int
+	options  map[string]string
+}
+
+func startProcess(ctx context.Context, spawn SpawnFunc, readyTimeout time.Duration, keepRaw bool) (*Process, error) {
+	conn, err := spawn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Process{conn: conn, client: uci.NewClient(conn, conn), multiPV: 1}
+	p.client.KeepRaw = keepRaw
+
+	hctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	if err := p.client.Handshake(hctx); err != nil {
+		// The process may have said why it never answered, e.g. that the
+		// binary does not exist.
+		if closeErr := p.kill(); errors.Is(closeErr, ports.ErrPermanent) {
+			return nil, closeErr
+		}
+		return nil, fmt.Errorf("uci handshake: %w", err)
+	}
+	if err := p.client.IsReady(hctx); err != nil {
+		p.kill()
+		return nil, err
+	}
+	p.options = defaultOptions(p.client.Options())
+	return p, nil
+}
+
+func (p *Process) kill() error {
+	p.client.Send("quit")
+	return p.conn.Close()
+}
+
+// Pool leases warm engine processes to analyses. At most size processes
+// exist at once; each is recycled after maxSearches searches or as soon as
+// it fails a health check.
+type Pool struct {
+	spawn        SpawnFunc
+	maxSearches  int
+	readyTimeout time.Duration
+	keepRaw      bool
+
+	slots chan struct{}
+	mu    sync.Mutex
+	idle  []*Process
+}
+
+func NewPool(spawn SpawnFunc, size, maxSearches int, readyTimeout time.Duration, keepRaw bool) *Pool {
+	if size <= 0 {
+		size = 1
+	}
+	return &Pool{
+		spawn:        spawn,
+		maxSearches:  maxSearches,
+		readyTimeout: readyTimeout,
+		keepRaw:      keepRaw,
+		slots:        make(chan struct{}, size),
+	}
+}
+
+func (ep *Pool) Lease(ctx context.Context) (*Process, error) {
+	select {
+	case ep.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return ep.take(ctx)
+}
+
+// Ping checks that an engine answers when a slot is free. It never waits
+// for one: when every slot is leased the engines are busy searching, which
+// is as healthy as they get.
+func (ep *Pool) Ping(ctx context.Context) error {
+	select {
+	case ep.slots <- struct{}{}:
+	default:
+		return nil
+	}
+	p, err := ep.take(ctx)
+	if err != nil {
+		return err
+	}
+	ep.Release(p, false)
+	return nil
+}
+
+// take returns a checked idle process or starts one, in a slot the caller
+// already holds; the slot is given back if that fails.
+func (ep *Pool) take(ctx context.Context) (*Process, error) {
+	for {
+		ep.mu.Lock()
+		if len(ep.idle) == 0 {
+			ep.mu.Unlock()
+			break
+		}
+		p := ep.idle[len(ep.idle)-1]
+		ep.idle = ep.idle[:len(ep.idle)-1]
+		ep.mu.Unlock()
+
+		if err := ep.check(ctx, p); err == nil {
+			return p, nil
+		}
+		p.kill()
+	}
+
+	p, err := startProcess(ctx, ep.spawn, ep.readyTimeout, ep.keepRaw)
+	if err != nil {
+		<-ep.slots
+		return nil, err
+	}
+	return p, nil
+}
+
+// check makes sure an idle engine is still alive and answering.
+func (ep *Pool) check(ctx context.Context, p *Process) error {
+	if err := p.client.Err(); err != nil {
+		return err
+	}
+	cctx, cancel := context.WithTimeout(ctx, ep.readyTimeout)
+	defer cancel()
+	return p.client.IsReady(cctx)
+}
+
+func (ep *Pool) Release(p *Process, broken bool) {
+	p.searches++
+	if broken || (ep.maxSearches > 0 && p.searches >= ep.maxSearches) {
+		p.kill()
+	} else {
+		ep.mu.Lock()
+		ep.idle = append(ep.idle, p)
+		ep.mu.Unlock()
+	}
+	<-ep.slots
+}
+
+func (ep *Pool) Close() {
+	ep.mu.Lock()
+	idle := ep.idle
+	ep.idle = nil
+	ep.mu.Unlock()
+	for _, p := range idle {
+		p.kill()
+	}
+}
@@ -0,0 +1,277 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/pgn"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"github.com/notnil/chess"
+)
+
+// Game is a parsed request: the position play started from and the moves
+// made since. The engine is always given the whole history so that it sees
+// repetitions and the fifty-move count.
+type Game struct {
+	Start *chess.Position
+	Moves []*chess.Move
+	// First is the first ply to analyze: plies First through len(Moves) are
+	// selected. It is len(Moves) unless a range of plies was requested.
+	First int
+	// positions holds Start followed by the position after each move.
+	positions []*chess.Position
+}
+
+func newGame(start *chess.Position, moves []*chess.Move) *Game {
+	g := &Game{Start: start, Moves: moves, First: len(moves), positions: []*chess.Position{start}}
+	pos := start
+	for _, m := range moves {
+		pos = pos.Update(m)
+		g.positions = append(g.positions, pos)
+	}
+	return g
+}
+
+// ParseGame validates the request and returns the game it describes, cut
+// after the last selected ply.
+func ParseGame(req ports.AnalyzeRequest) (*Game, error) {
+	g, err := parseGame(req)
+	if err != nil || req.Plies == nil {
+		return g, err
+	}
+	from, to := req.Plies.From, req.Plies.To
+	if to < 0 {
+		to = len(g.Moves)
+	}
+	if from < 0 || from > to || to > len(g.Moves) {
+		return nil, &ports.InputError{Field: "ply", Err: fmt.Errorf("plies %d to %d are outside the game (0 to %d)", from, to, len(g.Moves))}
+	}
+	g = g.At(to)
+	g.First = from
+	return g, nil
+}
+
+func parseGame(req ports.AnalyzeRequest) (*Game, error) {
+	if req.FEN == "" && req.PGN == "" && req.UCIMoves == "" && req.SANMoves == "" {
+		return nil, &ports.InputError{Err: errors.New("fen, pgn, uci or san required")}
+	}
+
+	if req.PGN != "" {
+		game, err := pgn.Parse(req.PGN)
+		if err != nil {
+			if errors.Is(err, ports.ErrIllegalMove) {
+				return nil, err
+			}
+			return nil, &ports.InputError{Field: "pgn", Err: err}
+		}
+		return newGame(game.Start, game.MainLine()), nil
+	}
+
+	start := chess.StartingPosition()
+	if req.FEN != "" {
+		var err error
+		if start, err = pgn.ParseFEN(req.FEN); err != nil {
+			return nil, &ports.InputError{Field: "fen", Err: err}
+		}
+	}
+
+	var moves []*chess.Move
+	var err error
+	switch {
+	case strings.TrimSpace(req.SANMoves) != "":
+		moves, err = decodeSANMoves(start, req.SANMoves)
+	case strings.TrimSpace(req.UCIMoves) != "":
+		moves, err = decodeUCIMoves(start, req.UCIMoves)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newGame(start, moves), nil
+}
+
+// BuildPosition validates the request and returns the UCI position command
+// together with the resulting position.
+func BuildPosition(req ports.AnalyzeRequest) (string, *chess.Position, error) {
+	g, err := ParseGame(req)
+	if err != nil {
+		return "", nil, err
+	}
+	return g.Command(), g.Final(), nil
+}
+
+// At returns the game up to the given ply.
+func (g *Game) At(ply int) *Game {
+	return &Game{Start: g.Start, Moves: g.Moves[:ply], First: ply, positions: g.positions[:ply+1]}
+}
+
+// Selected reports how many plies are to be analyzed.
+func (g *Game) Selected() int {
+	return len(g.Moves) - g.First + 1
+}
+
+func (g *Game) Final() *chess.Position {
+	return g.positions[len(g.positions)-1]
+}
+
+// Command writes "position startpos|fen ... [moves ...]". It is built from
+// the parsed position and moves only, so no client text reaches the engine.
+func (g *Game) Command() string {
+	var b strings.Builder
+	if fen := g.Start.String(); fen == chess.StartingPosition().String() {
+		b.WriteString("position startpos")
+	} else {
+		b.WriteString("position fen " + fen)
+	}
+	if len(g.Moves) > 0 {
+		b.WriteString(" moves")
+		for i, m := range g.Moves {
+			b.WriteString(" " + chess.UCINotation{}.Encode(g.positions[i], m))
+		}
+	}
+	return b.String()
+}
+
+// reversible returns the moves played since the last capture or pawn move,
+// the only part of the history in which the final position can recur.
+func (g *Game) reversible() []*chess.Move {
+	n := g.Final().HalfMoveClock()
+	if n > len(g.Moves) {
+		n = len(g.Moves)
+	}
+	return g.Moves[len(g.Moves)-n:]
+}
+
+// Draw reports the draw claims the history allows in the final position.
+func (g *Game) Draw() *ports.DrawStatus {
+	final := g.Final()
+	key := repetitionKey(final)
+	repetitions := 0
+	for _, pos := range g.positions[len(g.positions)-1-len(g.reversible()):] {
+		if repetitionKey(pos) == key {
+			repetitions++
+		}
+	}
+	clock := final.HalfMoveClock()
+	return &ports.DrawStatus{
+		Repetitions:         repetitions,
+		ThreefoldRepetition: repetitions >= 3,
+		HalfmoveClock:       clock,
+		FiftyMoveRule:       clock >= 100,
+	}
+}
+
+// repetitionKey identifies pos for repetition: placement, side to move,
+// castling rights and the en-passant square, which only counts when an
+// en-passant capture is actually legal. The FEN names it after every
+// double pawn push.
+func repetitionKey(pos *chess.Position) string {
+	fields := strings.Fields(NormalizeFEN(pos.String()))
+	if len(fields) == 4 && fields[3] != "-" {
+		legal := false
+		for _, m := range pos.ValidMoves() {
+			if m.HasTag(chess.EnPassant) {
+				legal = true
+				break
+			}
+		}
+		if !legal {
+			fields[3] = "-"
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+var (
+	uciMovePattern = regexp.MustCompile(`^[a-h][1-8][a-h][1-8][nbrq]?$`)
+	sanMovePattern = regexp.MustCompile(`^(?:[NBRQK]?[a-h]?[1-8]?x?[a-h][1-8](?:=?[NBRQ])?|O-O(?:-O)?|0-0(?:-0)?)[+#]?[!?]*$`)
+)
+
+func decodeSANMoves(start *chess.Position, sanMoves string) ([]*chess.Move, error) {
+	pos := start
+	alg := chess.AlgebraicNotation{}
+
+	var moves []*chess.Move
+	for _, token := range strings.Fields(sanMoves) {
+		moveToken := cleanSANToken(token)
+		if moveToken == "" {
+			continue
+		}
+		if !sanMovePattern.MatchString(moveToken) {
+			return nil, &ports.InputError{Field: "san", Err: fmt.Errorf("malformed move %q at index %d", moveToken, len(moves))}
+		}
+		move, err := alg.Decode(pos, moveToken)
+		if err != nil {
+			return nil, &ports.IllegalMoveError{Field: "san", Index: len(moves), Move: moveToken, FEN: pos.String()}
+		}
+		moves = append(moves, move)
+		pos = pos.Update(move)
+	}
+
+	if len(moves) == 0 {
+		return nil, &ports.InputError{Field: "san", Err: errors.New("no SAN moves parsed")}
+	}
+	return moves, nil
+}
+
+func decodeUCIMoves(start *chess.Position, uciMoves string) ([]*chess.Move, error) {
+	pos := start
+	var moves []*chess.Move
+	for i, token := range strings.Fields(uciMoves) {
+		if !uciMovePattern.MatchString(token) {
+			return nil, &ports.InputError{Field: "uci", Err: fmt.Errorf("malformed move %q at index %d", token, i)}
+		}
+		move, ok := decodeUCI(pos, token)
+		if !ok {
+			return nil, &ports.IllegalMoveError{Field: "uci", Index: i, Move: token, FEN: pos.String()}
+		}
+		moves = append(moves, move)
+		pos = pos.Update(move)
+	}
+	return moves, nil
+}
+
+// decodeUCI returns the legal move of pos written as uciMove. The notation
+// decoder alone accepts any from/to squares, legal or not.
+func decodeUCI(pos *chess.Position, uciMove string) (*chess.Move, bool) {
+	move, err := chess.UCINotation{}.Decode(pos, uciMove)
+	if err != nil {
+		return nil, false
+	}
+	for _, legal := range pos.ValidMoves() {
+		if legal.S1() == move.S1() && legal.S2() == move.S2() && legal.Promo() == move.Promo() {
+			return legal, true
+		}
+	}
+	return nil, false
+}
+
+// UCIToSAN converts a UCI move to SAN in pos, returning "" when illegal.
+func UCIToSAN(uciMove string, pos *chess.Position) string {
+	if uciMove == "" || pos == nil {
+		return ""
+	}
+	move, ok := decodeUCI(pos, uciMove)
+	if !ok {
+		return ""
+	}
+	return chess.AlgebraicNotation{}.Encode(pos, move)
+}
+
+func cleanSANToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	if token == "1-0" || token == "0-1" || token == "1/2-1/2" {
+		return ""
+	}
+	if strings.HasSuffix(token, ".") {
+		return ""
+	}
+	if strings.Contains(token, ".") {
+		parts := strings.Split(token, ".")
+		return parts[len(parts)-1]
+	}
+	return token
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"github.com/aminammar1/stockfish-go-ec2/internal/uci"
+	"github.com/notnil/chess"
+)
+
+func buildResult(best uci.BestMove, lines []uci.Info, raw []string, pos *chess.Position, includeRaw bool) ports.AnalyzeResult {
+	var bestMoveSAN string
+	if pos != nil && best.Move != "" {
+		bestMoveSAN = UCIToSAN(best.Move, pos)
+	}
+
+	result := ports.AnalyzeResult{
+		BestMoveUCI: best.Move,
+		BestMoveSAN: bestMoveSAN,
+	}
+	if includeRaw {
+		result.Raw = strings.Join(raw, "\n")
+	}
+	if pos != nil {
+		result.PositionFEN = pos.String()
+	}
+
+	for i, info := range lines {
+		line := buildLine(i+1, info, pos)
+		result.Lines = append(result.Lines, line)
+		if i == 0 {
+			result.Depth = info.Depth
+			result.Nodes = info.Nodes
+			result.NPS = info.NPS
+			result.PV = strings.Join(info.PV, " ")
+			result.EvaluationCp = line.EvaluationCp
+			result.EvaluationMate = line.EvaluationMate
+			result.EvalBar = line.EvalBar
+		}
+	}
+	if len(lines) == 0 && pos != nil {
+		scoreFinished(&result, pos)
+	}
+
+	return result
+}
+
+// scoreFinished scores a position without legal moves, for which the
+// engine only reports a score on a line without a PV: mate 0 when the side
+// to move is checkmated and 0 cp when it is stalemated.
+func scoreFinished(result *ports.AnalyzeResult, pos *chess.Position) {
+	switch pos.Status() {
+	case chess.Checkmate:
+		mate, bar := 0, 100
+		if pos.Turn() == chess.White {
+			bar = 0
+		}
+		result.EvaluationMate, result.EvalBar = &mate, &bar
+	case chess.Stalemate:
+		cp := 0
+		result.EvaluationCp, result.EvalBar = &cp, computeEvalBar(&cp, nil)
+	}
+}
+
+func buildLine(rank int, info uci.Info, pos *chess.Position) ports.PVLine {
+	line := ports.PVLine{
+		Rank:     rank,
+		Depth:    info.Depth,
+		MovesUCI: info.PV,
+		MovesSAN: pvToSAN(info.PV, pos),
+	}
+
+	// Stockfish reports score from side-to-move perspective
+	isWhiteToMove := pos == nil || pos.Turn() == chess.White
+
+	if info.Score != nil && info.Score.Cp != nil {
+		cp := *info.Score.Cp
+		if !isWhiteToMove {
+			cp = -cp // Flip for White's perspective
+		}
+		line.EvaluationCp = &cp
+	}
+	if info.Score != nil && info.Score.Mate != nil {
+		mate := *info.Score.Mate
+		if !isWhiteToMove {
+			mate = -mate // Flip for White's perspective
+		}
+		line.EvaluationMate = &mate
+	}
+	line.EvalBar = computeEvalBar(line.EvaluationCp, line.EvaluationMate)
+	return line
+}
+
+// pvToSAN converts a principal variation to SAN, stopping at the first
+// move that is not legal in the resulting position.
+func pvToSAN(pv []string, pos *chess.Position) []string {
+	if pos == nil {
+		return nil
+	}
+	alg := chess.AlgebraicNotation{}
+	out := make([]string, 0, len(pv))
+	for _, m := range pv {
+		move, ok := decodeUCI(pos, m)
+		if !ok {
+			break
+		}
+		out = append(out, alg.Encode(pos, move))
+		pos = pos.Update(move)
+	}
+	return out
+}
+
+func buildUpdate(info uci.Info, pos *chess.Position) ports.AnalysisUpdate {
+	line := buildLine(info.MultiPV, info, pos)
+	return ports.AnalysisUpdate{
+		Depth:          info.Depth,
+		SelDepth:       info.SelDepth,
+		MultiPV:        info.MultiPV,
+		EvaluationCp:   line.EvaluationCp,
+		EvaluationMate: line.EvaluationMate,
+		EvalBar:        line.EvalBar,
+		Nodes:          info.Nodes,
+		NPS:            info.NPS,
+		PVUCI:          line.MovesUCI,
+		PVSAN:          line.MovesSAN,
+	}
+}
+
+// forwardProgress passes scored info lines of search to fn until the search
+// ends or the returned function is called; fn is never called after that.
+// The returned function waits for the forwarder and may be called again.
+func forwardProgress(search *uci.Search, pos *chess.Position, fn func(ports.AnalysisUpdate)) func() {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case info, ok := <-search.Info():
+				if !ok {
+					return
+				}
+				if info.Score != nil && len(info.PV) > 0 {
+					fn(buildUpdate(info, pos))
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			select {
+			case <-search.Done():
+				// The info channel is closed; let the forwarder drain it.
+			default:
+				close(quit)
+			}
+			<-done
+		})
+	}
+}
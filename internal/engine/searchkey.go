@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"github.com/notnil/chess"
+)
+
+// SearchKey identifies the search a request would run. Key covers the
+// position without its move counters, the moves since the last capture or
+// pawn move (which decide repetitions), the clamped limits, the number of
+// lines, the layered options and, for a range of plies, the whole line.
+// For depth-only searches the depth is left out of Key so that a deeper
+// result can answer a shallower request. FEN and Draw describe this
+// request's own (last) position and history.
+type SearchKey struct {
+	Key   string
+	Depth int
+	FEN   string
+	Draw  *ports.DrawStatus
+}
+
+func NewSearchKey(req ports.AnalyzeRequest, cfg config.Config) (SearchKey, error) {
+	game, err := ParseGame(req)
+	if err != nil {
+		return SearchKey{}, err
+	}
+	pos := game.Final()
+	options, err := layeredOptions(cfg, req.Profile, req.Options)
+	if err != nil {
+		return SearchKey{}, err
+	}
+
+	limits := ClampLimits(req.Limits, cfg, pos.Turn())
+	depth := limits.Depth
+	if limits == (ports.SearchLimits{Depth: depth}) {
+		limits.Depth = 0
+	}
+
+	fen := pos.String()
+	parts := []string{repetitionKey(pos)}
+	if tail := game.reversible(); len(tail) > 0 {
+		history := "history"
+		start := len(game.Moves) - len(tail)
+		for i, m := range tail {
+			history += " " + chess.UCINotation{}.Encode(game.positions[start+i], m)
+		}
+		parts = append(parts, history)
+	}
+	if game.Selected() > 1 {
+		parts = append(parts, "plies "+strconv.Itoa(game.First)+" "+game.Command())
+	}
+	parts = append(parts,
+		"go "+goArgs(limits),
+		"multipv "+strconv.Itoa(clampMultiPV(req.MultiPV, cfg)),
+	)
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+"="+options[name])
+	}
+	return SearchKey{Key: strings.Join(parts, "|"), Depth: depth, FEN: fen, Draw: game.Draw()}, nil
+}
+
+// Exact identifies searches that are interchangeable as they run, depth
+// included.
+func (k SearchKey) Exact() string {
+	return k.Key + "|depth " + strconv.Itoa(k.Depth)
+}
+
+// NormalizeFEN drops the halfmove clock and fullmove number, which do not
+// change what the engine finds in a position.
+func NormalizeFEN(fen string) string {
+	fields := strings.Fields(fen)
+	if len(fields) > 4 {
+		fields = fields[:4]
+	}
+	return strings.Join(fields, " ")
+}
+
+// layeredOptions merges the default profile, the named profile and the
+// request's options the way resolveOptions does, without the engine's
+// advertised defaults, which are the same for every search.
+func layeredOptions(cfg config.Config, profile string, requested map[string]string) (map[string]string, error) {
+	out := map[string]string{}
+	layer := func(values map[string]string) {
+		for k, v := range values {
+			name, ok := canonicalOption(k)
+			if !ok {
+				name = strings.TrimSpace(k)
+			}
+			out[name] = strings.ToLower(strings.TrimSpace(v))
+		}
+	}
+	layer(cfg.EngineProfiles["default"])
+	if profile != "" {
+		values, ok := cfg.EngineProfiles[profile]
+		if !ok {
+			return nil, &ports.InputError{Field: "profile", Err: fmt.Errorf("unknown engine profile %q", profile)}
+		}
+		layer(values)
+	}
+	layer(requested)
+	return out, nil
+}
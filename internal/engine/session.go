@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+	"github.com/aminammar1/stockfish-go-ec2/internal/uci"
+)
+
+var errSessionPlies = &ports.InputError{Field: "ply", Err: errors.New("a session analyzes a single ply")}
+
+// Session holds a leased process running "go infinite" until closed.
+type Session struct {
+	engine  *Engine
+	proc    *Process
+	options map[string]string
+
+	mu     sync.Mutex
+	search *uci.Search
+	game   *Game
+	closed bool
+}
+
+func (e *Engine) StartSession(ctx context.Context, req ports.AnalyzeRequest) (ports.EngineSession, error) {
+	game, err := ParseGame(req)
+	if err != nil {
+		return nil, err
+	}
+	if game.Selected() > 1 {
+		return nil, errSessionPlies
+	}
+
+	proc, err := e.pool.Lease(ctx)
+	if err != nil {
+		return nil, unavailable(ctx, err)
+	}
+	options, err := e.resolveOptions(proc.client.Options(), req.Profile, req.Options)
+	if err != nil {
+		e.pool.Release(proc, false)
+		return nil, err
+	}
+	if err := e.applyOptions(ctx, proc, options); err != nil {
+		e.pool.Release(proc, true)
+		return nil, unavailable(ctx, err)
+	}
+	if err := e.setMultiPV(ctx, proc, req.MultiPV); err != nil {
+		e.pool.Release(proc, true)
+		return nil, unavailable(ctx, err)
+	}
+
+	s := &Session{engine: e, proc: proc, options: options}
+	if err := s.start(ctx, game); err != nil {
+		e.pool.Release(proc, true)
+		return nil, unavailable(ctx, err)
+	}
+	return s, nil
+}
+
+func (s *Session) start(ctx context.Context, game *Game) error {
+	if err := s.engine.loadPosition(ctx, s.proc, game.Command()); err != nil {
+		return err
+	}
+	search, err := s.proc.client.Go("infinite")
+	if err != nil {
+		return err
+	}
+	s.search = search
+	s.game = game
+	return nil
+}
+
+func (s *Session) Snapshot() ports.AnalyzeResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines := s.search.Latest()
+	var best uci.BestMove
+	if len(lines) > 0 && len(lines[0].PV) > 0 {
+		best.Move = lines[0].PV[0]
+	}
+	result := buildResult(best, lines, nil, s.game.Final(), false)
+	result.Options = s.options
+	result.Draw = s.game.Draw()
+	return result
+}
+
+func (s *Session) SetPosition(ctx context.Context, req ports.AnalyzeRequest) error {
+	game, err := ParseGame(req)
+	if err != nil {
+		return err
+	}
+	if game.Selected() > 1 {
+		return errSessionPlies
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ports.ErrSessionClosed
+	}
+	if !s.engine.stop(s.search) {
+		s.closeLocked(true)
+		return fmt.Errorf("%w: engine did not stop", ports.ErrSessionClosed)
+	}
+	if err := s.start(ctx, game); err != nil {
+		s.closeLocked(true)
+		return fmt.Errorf("%w: %w", ports.ErrSessionClosed, err)
+	}
+	return nil
+}
+
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	// An engine that ignores stop is quit instead of being reused.
+	s.closeLocked(!s.engine.stop(s.search))
+	return nil
+}
+
+func (s *Session) closeLocked(broken bool) {
+	s.closed = true
+	s.engine.pool.Release(s.proc, broken)
+}
@@ -0,0 +1,37 @@
+package fakeengine
+
+import (
+	"context"
+	"io"
+)
+
+// conn connects an in-process engine through pipes so it can stand in for
+// a spawned Stockfish process.
+type conn struct {
+	io.Reader
+	io.WriteCloser
+	stdout *io.PipeReader
+	done   chan struct{}
+}
+
+// Spawn starts the engine in a goroutine and returns its stdio. Closing
+// the returned value ends the engine like "quit" would.
+func (e *Engine) Spawn(ctx context.Context) (io.ReadWriteCloser, error) {
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	c := &conn{Reader: stdoutR, WriteCloser: stdinW, stdout: stdoutR, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		err := e.Run(stdinR, stdoutW)
+		stdoutW.CloseWithError(err)
+		stdinR.Close()
+	}()
+	return c, nil
+}
+
+func (c *conn) Close() error {
+	c.WriteCloser.Close()
+	c.stdout.Close()
+	<-c.done
+	return nil
+}
@@ -0,0 +1,314 @@
+// Package fakeengine is a scriptable stand-in for Stockfish that speaks
+// enough UCI for the adapters, tests and the server's demo mode.
+package fakeengine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// ErrCrashed is returned by Run when a rule simulates a crash.
+var ErrCrashed = errors.New("fake engine crashed")
+
+// Rule answers commands starting with Match. Output lines are written one
+// by one, each after Delay. Crash ends the engine after the output; Hang
+// swallows the command without answering.
+type Rule struct {
+	Match string
+	Lines []string
+	Delay time.Duration
+	Crash bool
+	Hang  bool
+	// Times limits how often the rule fires; zero means always.
+	Times int
+}
+
+// Engine is a fake UCI engine. Commands without a matching rule get a
+// plausible default answer: the handshake, readyok, and a short search
+// that plays a legal move in the current position.
+type Engine struct {
+	Name    string
+	Options []string
+	Rules   []Rule
+	// SearchDelay is the pause between default info lines.
+	SearchDelay time.Duration
+
+	mu    sync.Mutex
+	fired map[int]int
+}
+
+func New(rules ...Rule) *Engine {
+	return &Engine{Rules: rules}
+}
+
+func (e *Engine) rule(cmd string) (Rule, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.fired == nil {
+		e.fired = map[int]int{}
+	}
+	for i, r := range e.Rules {
+		if !strings.HasPrefix(cmd, r.Match) {
+			continue
+		}
+		if r.Times > 0 && e.fired[i] >= r.Times {
+			continue
+		}
+		e.fired[i]++
+		return r, true
+	}
+	return Rule{}, false
+}
+
+// Run serves one engine session until "quit", end of input or a crash.
+func (e *Engine) Run(in io.Reader, out io.Writer) error {
+	s := &state{engine: e, out: out, pos: chess.StartingPosition()}
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		cmd := strings.TrimSpace(scanner.Text())
+		if cmd == "" {
+			continue
+		}
+		if r, ok := e.rule(cmd); ok {
+			if r.Hang {
+				continue
+			}
+			for _, line := range r.Lines {
+				time.Sleep(r.Delay)
+				s.println(line)
+			}
+			if r.Crash {
+				s.stopInfinite()
+				return ErrCrashed
+			}
+			continue
+		}
+		if quit := s.handle(cmd); quit {
+			break
+		}
+	}
+	s.stopInfinite()
+	return scanner.Err()
+}
+
+type state struct {
+	engine *Engine
+	out    io.Writer
+	outMu  sync.Mutex
+	pos    *chess.Position
+
+	infinite chan struct{}
+	done     chan struct{}
+}
+
+func (s *state) println(line string) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	fmt.Fprintln(s.out, line)
+}
+
+func (s *state) handle(cmd string) bool {
+	fields := strings.Fields(cmd)
+	switch fields[0] {
+	case "uci":
+		name := s.engine.Name
+		if name == "" {
+			name = "Fake Stockfish"
+		}
+		s.println("id name " + name)
+		s.println("id author stockfish-go-ec2")
+		options := s.engine.Options
+		if options == nil {
+			options = DefaultOptions
+		}
+		for _, o := range options {
+			s.println(o)
+		}
+		s.println("uciok")
+	case "isready":
+		s.println("readyok")
+	case "ucinewgame", "setoption":
+	case "position":
+		if pos, err := parsePosition(fields[1:]); err == nil {
+			s.pos = pos
+		}
+	case "go":
+		s.stopInfinite()
+		if len(fields) > 1 && fields[1] == "infinite" {
+			s.startInfinite()
+			return false
+		}
+		s.search(goDepth(fields[1:]))
+	case "stop":
+		s.stopInfinite()
+	case "quit":
+		return true
+	}
+	return false
+}
+
+// DefaultOptions mirrors the options Stockfish advertises that the
+// service may set.
+var DefaultOptions = []string{
+	"option name Threads type spin default 1 min 1 max 1024",
+	"option name Hash type spin default 16 min 1 max 33554432",
+	"option name MultiPV type spin default 1 min 1 max 256",
+	"option name Skill Level type spin default 20 min 0 max 20",
+	"option name Move Overhead type spin default 10 min 0 max 5000",
+	"option name UCI_LimitStrength type check default false",
+	"option name UCI_Elo type spin default 1320 min 1320 max 3190",
+}
+
+func goDepth(args []string) int {
+	depth := 5
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "depth" {
+			if v, err := strconv.Atoi(args[i+1]); err == nil {
+				depth = v
+			}
+		}
+	}
+	if depth > 10 {
+		depth = 10
+	}
+	return depth
+}
+
+func (s *state) search(depth int) {
+	move, score := s.choose()
+	for d := 1; d <= depth; d++ {
+		time.Sleep(s.engine.SearchDelay)
+		s.info(d, move, score)
+	}
+	s.bestmove(move)
+}
+
+func (s *state) startInfinite() {
+	s.infinite = make(chan struct{})
+	s.done = make(chan struct{})
+	stop, done := s.infinite, s.done
+	move, score := s.choose()
+	go func() {
+		defer close(done)
+		delay := s.engine.SearchDelay
+		if delay <= 0 {
+			delay = 50 * time.Millisecond
+		}
+		for d := 1; ; d++ {
+			s.info(d, move, score)
+			select {
+			case <-stop:
+				s.bestmove(move)
+				return
+			case <-time.After(delay):
+			}
+		}
+	}()
+}
+
+func (s *state) stopInfinite() {
+	if s.infinite == nil {
+		return
+	}
+	close(s.infinite)
+	<-s.done
+	s.infinite = nil
+}
+
+func (s *state) info(depth int, move string, score int) {
+	if move == "" {
+		s.println("info depth 0 score mate 0")
+		return
+	}
+	nodes := depth * 1000
+	s.println(fmt.Sprintf("info depth %d seldepth %d multipv 1 score cp %d nodes %d nps %d time %d pv %s",
+		depth, depth+2, score, nodes, nodes*100, depth*10, move))
+}
+
+func (s *state) bestmove(move string) {
+	if move == "" {
+		move = "(none)"
+	}
+	s.println("bestmove " + move)
+}
+
+// choose picks the legal move with the best material outcome, ties going
+// to the first move in generation order.
+func (s *state) choose() (string, int) {
+	moves := s.pos.ValidMoves()
+	if len(moves) == 0 {
+		return "", 0
+	}
+	notation := chess.UCINotation{}
+	best, bestScore := moves[0], -1<<30
+	for _, m := range moves {
+		score := -material(s.pos.Update(m))
+		if score > bestScore {
+			best, bestScore = m, score
+		}
+	}
+	return notation.Encode(s.pos, best), bestScore
+}
+
+// material is the piece balance in centipawns for the side to move.
+func material(pos *chess.Position) int {
+	values := map[chess.PieceType]int{chess.Pawn: 100, chess.Knight: 300, chess.Bishop: 300, chess.Rook: 500, chess.Queen: 900}
+	total := 0
+	for _, p := range pos.Board().SquareMap() {
+		v := values[p.Type()]
+		if p.Color() == pos.Turn() {
+			total += v
+		} else {
+			total -= v
+		}
+	}
+	return total
+}
+
+func parsePosition(args []string) (*chess.Position, error) {
+	if len(args) == 0 {
+		return nil, errors.New("empty position")
+	}
+	var pos *chess.Position
+	rest := args[1:]
+	switch args[0] {
+	case "startpos":
+		pos = chess.StartingPosition()
+	case "fen":
+		end := len(rest)
+		for i, a := range rest {
+			if a == "moves" {
+				end = i
+				break
+			}
+		}
+		opt, err := chess.FEN(strings.Join(rest[:end], " "))
+		if err != nil {
+			return nil, err
+		}
+		pos = chess.NewGame(opt).Position()
+		rest = rest[end:]
+	default:
+		return nil, fmt.Errorf("unknown position %q", args[0])
+	}
+
+	if len(rest) > 0 && rest[0] == "moves" {
+		notation := chess.UCINotation{}
+		for _, m := range rest[1:] {
+			move, err := notation.Decode(pos, m)
+			if err != nil {
+				return nil, err
+			}
+			pos = pos.Update(move)
+		}
+	}
+	return pos, nil
+}
@@ -0,0 +1,74 @@
+package fakeengine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseScript reads rules from a transcript:
+//
+//	> go             start a rule for commands beginning with "go"
+//	< info depth 1   output line
+//	! delay 200ms    pause before each output line of the rule
+//	! times 1        fire the rule at most this often
+//	! crash          exit after the output
+//	! hang           never answer the command
+//
+// Blank lines and lines starting with # are ignored.
+func ParseScript(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		kind, rest := trimmed[:1], strings.TrimSpace(trimmed[1:])
+		if kind == ">" {
+			rules = append(rules, Rule{Match: rest})
+			continue
+		}
+		if len(rules) == 0 {
+			return nil, fmt.Errorf("script line %d: %q before the first \"> command\"", lineNo, line)
+		}
+		rule := &rules[len(rules)-1]
+
+		switch kind {
+		case "<":
+			rule.Lines = append(rule.Lines, rest)
+		case "!":
+			directive, arg, _ := strings.Cut(rest, " ")
+			switch directive {
+			case "delay":
+				d, err := time.ParseDuration(strings.TrimSpace(arg))
+				if err != nil {
+					return nil, fmt.Errorf("script line %d: %w", lineNo, err)
+				}
+				rule.Delay = d
+			case "times":
+				n, err := strconv.Atoi(strings.TrimSpace(arg))
+				if err != nil {
+					return nil, fmt.Errorf("script line %d: %w", lineNo, err)
+				}
+				rule.Times = n
+			case "crash":
+				rule.Crash = true
+			case "hang":
+				rule.Hang = true
+			default:
+				return nil, fmt.Errorf("script line %d: unknown directive %q", lineNo, directive)
+			}
+		default:
+			return nil, fmt.Errorf("script line %d: expected '>', '<' or '!', got %q", lineNo, line)
+		}
+	}
+	return rules, scanner.Err()
+}
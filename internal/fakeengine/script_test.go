@@ -0,0 +1,37 @@
+package fakeengine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScript(t *testing.T) {
+	rules, err := ParseScript(strings.NewReader(`
+# first search crashes
+> go
+< info depth 1 score cp 10 pv e2e4
+! delay 20ms
+! crash
+! times 1
+> isready
+! hang
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+	goRule := rules[0]
+	if goRule.Match != "go" || len(goRule.Lines) != 1 || goRule.Delay != 20*time.Millisecond || !goRule.Crash || goRule.Times != 1 {
+		t.Errorf("go rule = %+v", goRule)
+	}
+	if !rules[1].Hang {
+		t.Errorf("isready rule = %+v", rules[1])
+	}
+
+	if _, err := ParseScript(strings.NewReader("< orphan line")); err == nil {
+		t.Error("output before a command should fail")
+	}
+}
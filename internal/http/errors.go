@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/app"
+	"github.com/aminammar1/stockfish-go-ec2/internal/pgn"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// Error codes are part of the API: clients branch on them, so they never
+// change once published.
+const (
+	codeInvalidInput      = "invalid_input"
+	codeIllegalMove       = "illegal_move"
+	codeNotFound          = "not_found"
+	codeSessionClosed     = "session_closed"
+	codeOverloaded        = "overloaded"
+	codeEngineUnavailable = "engine_unavailable"
+	codeTimeout           = "timeout"
+	codeEngineError       = "engine_error"
+)
+
+// errorResponse is the body of every failed request.
+type errorResponse struct {
+	Code    string         `json:"code" example:"illegal_move"`
+	Error   string         `json:"error" example:"uci: illegal move \"e2e5\" at index 0"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+var (
+	errInvalidJSON  = &ports.InputError{Err: errors.New("invalid json")}
+	errInvalidQuery = &ports.InputError{Err: errors.New("invalid query")}
+)
+
+// classify maps an error to its status, body and Retry-After hint.
+func classify(err error) (int, errorResponse, time.Duration) {
+	body := errorResponse{Error: err.Error()}
+
+	var input *ports.InputError
+	var illegal *ports.IllegalMoveError
+	var overloaded *ports.OverloadedError
+	var unavailable *ports.UnavailableError
+	switch {
+	case errors.As(err, &illegal):
+		body.Code = codeIllegalMove
+		body.Details = withLocation(map[string]any{"field": illegal.Field, "moveIndex": illegal.Index, "move": illegal.Move, "fen": illegal.FEN}, err)
+		if illegal.Variation {
+			body.Details["variation"] = true
+		}
+		return http.StatusUnprocessableEntity, body, 0
+	case errors.As(err, &input):
+		body.Code = codeInvalidInput
+		if input.Field != "" {
+			body.Details = withLocation(map[string]any{"field": input.Field}, err)
+		}
+		return http.StatusBadRequest, body, 0
+	case errors.Is(err, app.ErrSessionNotFound), errors.Is(err, app.ErrJobNotFound):
+		body.Code = codeNotFound
+		return http.StatusNotFound, body, 0
+	case errors.Is(err, ports.ErrSessionClosed):
+		body.Code = codeSessionClosed
+		return http.StatusGone, body, 0
+	case errors.Is(err, app.ErrTooManySessions), errors.Is(err, app.ErrJobQueueFull):
+		body.Code = codeOverloaded
+		return http.StatusTooManyRequests, body, 0
+	case errors.As(err, &overloaded):
+		body.Code = codeOverloaded
+		body.Details = map[string]any{"queueFull": overloaded.QueueFull}
+		if overloaded.QueueFull {
+			return http.StatusTooManyRequests, body, overloaded.RetryAfter
+		}
+		return http.StatusServiceUnavailable, body, overloaded.RetryAfter
+	case errors.As(err, &unavailable):
+		body.Code = codeEngineUnavailable
+		return http.StatusServiceUnavailable, body, unavailable.RetryAfter
+	case errors.Is(err, ports.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		body.Code = codeTimeout
+		return http.StatusGatewayTimeout, body, 0
+	}
+	body.Code = codeEngineError
+	return http.StatusBadGateway, body, 0
+}
+
+// withLocation adds where in the PGN text a parse error was found.
+func withLocation(details map[string]any, err error) map[string]any {
+	var perr *pgn.Error
+	if errors.As(err, &perr) {
+		details["line"] = perr.Line
+		details["column"] = perr.Column
+	}
+	return details
+}
+
+func writeError(c *gin.Context, err error) {
+	status, body, retryAfter := classify(err)
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	c.JSON(status, body)
+}
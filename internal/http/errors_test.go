@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/app"
+	"github.com/aminammar1/stockfish-go-ec2/internal/pgn"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+func TestWriteError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	illegal := &ports.IllegalMoveError{Field: "uci", Index: 1, Move: "e2e5", FEN: "8/8/8/8/8/8/8/K6k w - - 0 1"}
+
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		code       string
+		details    map[string]any
+		retryAfter string
+	}{
+		{"input", &ports.InputError{Field: "fen", Err: errors.New("bad fen")}, http.StatusBadRequest, codeInvalidInput,
+			map[string]any{"field": "fen"}, ""},
+		{"input without field", errInvalidJSON, http.StatusBadRequest, codeInvalidInput, nil, ""},
+		{"pgn input", &ports.InputError{Field: "pgn", Err: &pgn.Error{Line: 3, Column: 7, Err: errors.New("unterminated comment")}}, http.StatusBadRequest, codeInvalidInput,
+			map[string]any{"field": "pgn", "line": float64(3), "column": float64(7)}, ""},
+		{"illegal move", illegal, http.StatusUnprocessableEntity, codeIllegalMove,
+			map[string]any{"field": "uci", "moveIndex": float64(1), "move": "e2e5", "fen": illegal.FEN}, ""},
+		{"illegal move in pgn", &pgn.Error{Line: 2, Column: 4, Err: illegal}, http.StatusUnprocessableEntity, codeIllegalMove,
+			map[string]any{"field": "uci", "moveIndex": float64(1), "move": "e2e5", "fen": illegal.FEN, "line": float64(2), "column": float64(4)}, ""},
+		{"illegal move in variation", &pgn.Error{Line: 1, Column: 11, Err: &ports.IllegalMoveError{Field: "pgn", Index: 0, Variation: true, Move: "Nf6", FEN: illegal.FEN}}, http.StatusUnprocessableEntity, codeIllegalMove,
+			map[string]any{"field": "pgn", "moveIndex": float64(0), "move": "Nf6", "fen": illegal.FEN, "variation": true, "line": float64(1), "column": float64(11)}, ""},
+		{"session not found", app.ErrSessionNotFound, http.StatusNotFound, codeNotFound, nil, ""},
+		{"session closed", fmt.Errorf("%w: engine did not stop", ports.ErrSessionClosed), http.StatusGone, codeSessionClosed, nil, ""},
+		{"job not found", app.ErrJobNotFound, http.StatusNotFound, codeNotFound, nil, ""},
+		{"too many sessions", app.ErrTooManySessions, http.StatusTooManyRequests, codeOverloaded, nil, ""},
+		{"job queue full", app.ErrJobQueueFull, http.StatusTooManyRequests, codeOverloaded, nil, ""},
+		{"queue full", &ports.OverloadedError{QueueFull: true, RetryAfter: 1500 * time.Millisecond}, http.StatusTooManyRequests, codeOverloaded,
+			map[string]any{"queueFull": true}, "2"},
+		{"queue wait", &ports.OverloadedError{Waited: 10 * time.Second, RetryAfter: 3 * time.Second}, http.StatusServiceUnavailable, codeOverloaded,
+			map[string]any{"queueFull": false}, "3"},
+		{"unavailable", &ports.UnavailableError{Err: errors.New("dial tcp: refused"), RetryAfter: 30 * time.Second}, http.StatusServiceUnavailable, codeEngineUnavailable, nil, "30"},
+		{"unavailable without hint", &ports.UnavailableError{Err: errors.New("engine crashed")}, http.StatusServiceUnavailable, codeEngineUnavailable, nil, ""},
+		{"timeout", fmt.Errorf("%w: %w", ports.ErrTimeout, context.DeadlineExceeded), http.StatusGatewayTimeout, codeTimeout, nil, ""},
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout, codeTimeout, nil, ""},
+		{"other", errors.New("uci: unexpected output"), http.StatusBadGateway, codeEngineError, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			writeError(c, tt.err)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			var body errorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tt.code || body.Error != tt.err.Error() {
+				t.Errorf("body = %+v, want code %q", body, tt.code)
+			}
+			if !reflect.DeepEqual(body.Details, tt.details) {
+				t.Errorf("details = %v, want %v", body.Details, tt.details)
+			}
+		})
+	}
+}
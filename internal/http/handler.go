@@ -1,7 +1,11 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -11,73 +15,187 @@ import (
 )
 
 type analyzeRequest struct {
-	FEN string `json:"fen" example:"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"`
-	PGN string `json:"pgn" example:"1. e4 e5 2. Nf3 Nc6 3. Bb5 a6"`
-	UCI string `json:"uci" example:"e2e4 e7e5 g1f3 b8c6"`
-	SAN string `json:"san" example:"e4 e5 Nf3 Nc6"`
+	FEN string `json:"fen" form:"fen" example:"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"`
+	PGN string `json:"pgn" form:"pgn" example:"1. e4 e5 2. Nf3 Nc6 3. Bb5 a6"`
+	UCI string `json:"uci" form:"uci" example:"e2e4 e7e5 g1f3 b8c6"`
+	SAN string `json:"san" form:"san" example:"e4 e5 Nf3 Nc6"`
+
+	Depth     int `json:"depth,omitempty" form:"depth" example:"12"`
+	MoveTime  int `json:"movetime,omitempty" form:"movetime" example:"1000"`
+	Nodes     int `json:"nodes,omitempty" form:"nodes"`
+	Mate      int `json:"mate,omitempty" form:"mate"`
+	WTime     int `json:"wtime,omitempty" form:"wtime"`
+	BTime     int `json:"btime,omitempty" form:"btime"`
+	WInc      int `json:"winc,omitempty" form:"winc"`
+	BInc      int `json:"binc,omitempty" form:"binc"`
+	MovesToGo int `json:"movestogo,omitempty" form:"movestogo"`
+
+	MultiPV  int               `json:"multipv,omitempty" form:"multipv" example:"3"`
+	Profile  string            `json:"profile,omitempty" form:"profile"`
+	Options  map[string]string `json:"options,omitempty"`
+	Priority string            `json:"priority,omitempty" form:"priority" enums:"interactive,normal,batch"`
+	// Ply is a ply number, a range such as "10-20", or "all".
+	Ply plySelector `json:"ply,omitempty" form:"ply" swaggertype:"string" example:"10-20"`
+}
+
+// plySelector accepts a JSON number as well as a string.
+type plySelector string
+
+func (p *plySelector) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*p = plySelector(strconv.Itoa(n))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("ply must be a number or a string")
+	}
+	*p = plySelector(s)
+	return nil
+}
+
+// plies parses the selector: "17" is that ply alone, "10-20" a range and
+// "all" every ply from the starting position on.
+func (p plySelector) plies() (*ports.PlyRange, error) {
+	s := strings.TrimSpace(string(p))
+	if s == "" {
+		return nil, nil
+	}
+	if s == "all" {
+		return &ports.PlyRange{From: 0, To: -1}, nil
+	}
+	from, to, isRange := strings.Cut(s, "-")
+	first, err := strconv.Atoi(from)
+	if err != nil || first < 0 {
+		return nil, &ports.InputError{Field: "ply", Err: fmt.Errorf("invalid ply %q (want a number, a range such as 10-20, or all)", s)}
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.Atoi(to); err != nil || last < first {
+			return nil, &ports.InputError{Field: "ply", Err: fmt.Errorf("invalid ply range %q", s)}
+		}
+	}
+	return &ports.PlyRange{From: first, To: last}, nil
+}
+
+// toPort checks that exactly one position input is given and converts the
+// request for the service.
+func (r analyzeRequest) toPort() (ports.AnalyzeRequest, error) {
+	fen := strings.TrimSpace(r.FEN)
+	pgn := strings.TrimSpace(r.PGN)
+	uci := strings.TrimSpace(r.UCI)
+	san := strings.TrimSpace(r.SAN)
+
+	provided := 0
+	if fen != "" {
+		provided++
+	}
+	if pgn != "" {
+		provided++
+	}
+	if uci != "" {
+		provided++
+	}
+	if san != "" {
+		provided++
+	}
+
+	if provided != 1 {
+		return ports.AnalyzeRequest{}, &ports.InputError{Err: errors.New("provide exactly one of: fen, pgn, uci, san")}
+	}
+
+	priority := strings.TrimSpace(r.Priority)
+	switch priority {
+	case "", ports.PriorityInteractive, ports.PriorityNormal, ports.PriorityBatch:
+	default:
+		return ports.AnalyzeRequest{}, &ports.InputError{Field: "priority", Err: errors.New("must be one of: interactive, normal, batch")}
+	}
+
+	plies, err := r.Ply.plies()
+	if err != nil {
+		return ports.AnalyzeRequest{}, err
+	}
+
+	return ports.AnalyzeRequest{
+		FEN:      fen,
+		PGN:      pgn,
+		UCIMoves: uci,
+		SANMoves: san,
+		Limits:   r.limits(),
+		MultiPV:  r.MultiPV,
+		Profile:  r.Profile,
+		Options:  r.Options,
+		Priority: priority,
+		Plies:    plies,
+	}, nil
+}
+
+func (r analyzeRequest) limits() ports.SearchLimits {
+	return ports.SearchLimits{
+		Depth:     r.Depth,
+		MoveTime:  r.MoveTime,
+		Nodes:     r.Nodes,
+		Mate:      r.Mate,
+		WTime:     r.WTime,
+		BTime:     r.BTime,
+		WInc:      r.WInc,
+		BInc:      r.BInc,
+		MovesToGo: r.MovesToGo,
+	}
 }
 
 // @Summary Health check
-// @Description Checks SSH connectivity to the Stockfish EC2 instance
+// @Description Checks SSH connectivity to the Stockfish EC2 instance and reports the admission queue and circuit breaker under "components".
 // @Tags Health
-// @Success 200 {object} map[string]string
-// @Failure 503 {object} map[string]string
+// @Success 200 {object} map[string]any
+// @Failure 503 {object} map[string]any
 // @Router /health [get]
 func healthHandler(svc *app.ChessService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if err := svc.Health(c.Request.Context()); err != nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": err.Error()})
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "error": err.Error(), "components": svc.Status()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "components": svc.Status()})
 	}
 }
 
 // @Summary Analyze position
 // @Description Analyze a position by providing exactly ONE of: fen, pgn, uci, san.
+// @Description Optional search limits (depth, movetime, nodes, mate, wtime, btime, winc, binc, movestogo; times in ms) are capped by the server maximums.
+// @Description multipv returns the top N candidate lines in "lines", best first.
+// @Description profile and options set engine options (Hash, Threads, Skill Level, UCI_LimitStrength, UCI_Elo, Move Overhead); the values in effect are returned in "options".
+// @Description priority (interactive, normal, batch) decides who gets the next free engine when searches are queued.
 // @Tags Analysis
 // @Accept json
 // @Produce json
 // @Param request body analyzeRequest true "Analyze request (exactly one of fen|pgn|uci|san)"
 // @Success 200 {object} ports.AnalyzeResult
-// @Failure 400 {object} map[string]string
-// @Failure 502 {object} map[string]string
+// @Failure 400 {object} errorResponse "invalid_input"
+// @Failure 422 {object} errorResponse "illegal_move, with the move index in details"
+// @Failure 429 {object} errorResponse "overloaded"
+// @Failure 502 {object} errorResponse "engine_error"
+// @Failure 503 {object} errorResponse "engine_unavailable or overloaded"
+// @Failure 504 {object} errorResponse "timeout"
 // @Router /analyze [post]
 func analyzeHandler(svc *app.ChessService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req analyzeRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+			writeError(c, errInvalidJSON)
 			return
 		}
 
-		fen := strings.TrimSpace(req.FEN)
-		pgn := strings.TrimSpace(req.PGN)
-		uci := strings.TrimSpace(req.UCI)
-		san := strings.TrimSpace(req.SAN)
-
-		provided := 0
-		if fen != "" {
-			provided++
-		}
-		if pgn != "" {
-			provided++
-		}
-		if uci != "" {
-			provided++
-		}
-		if san != "" {
-			provided++
-		}
-
-		if provided != 1 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "provide exactly one of: fen, pgn, uci, san"})
+		analyzeReq, err := req.toPort()
+		if err != nil {
+			writeError(c, err)
 			return
 		}
 
-		result, err := svc.Analyze(c.Request.Context(), ports.AnalyzeRequest{FEN: fen, PGN: pgn, UCIMoves: uci, SANMoves: san})
+		result, err := svc.Analyze(c.Request.Context(), analyzeReq)
 		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			writeError(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, result)
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/app"
+)
+
+// @Summary Submit analysis job
+// @Description Queues an analysis with the same input as /analyze and returns immediately. Poll the job for progress and the result.
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param request body analyzeRequest true "Analyze request (exactly one of fen|pgn|uci|san)"
+// @Success 202 {object} app.JobInfo
+// @Failure 400 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Router /jobs [post]
+func submitJobHandler(jobs *app.JobManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req analyzeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, errInvalidJSON)
+			return
+		}
+		analyzeReq, err := req.toPort()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		info, err := jobs.Submit(analyzeReq)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Header("Location", "/api/v1/jobs/"+info.ID)
+		c.JSON(http.StatusAccepted, info)
+	}
+}
+
+// @Summary Read analysis job
+// @Description Returns the job status (queued, running, done, failed, canceled), the latest engine update while running and the result once done.
+// @Tags Jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} app.JobInfo
+// @Failure 404 {object} errorResponse
+// @Router /jobs/{id} [get]
+func getJobHandler(jobs *app.JobManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info, err := jobs.Get(c.Param("id"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+// @Summary Cancel analysis job
+// @Description Cancels a queued or running job. Deleting a finished job discards it.
+// @Tags Jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} app.JobInfo
+// @Failure 404 {object} errorResponse
+// @Router /jobs/{id} [delete]
+func cancelJobHandler(jobs *app.JobManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info, err := jobs.Cancel(c.Param("id"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, info)
+	}
+}
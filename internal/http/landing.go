@@ -53,6 +53,7 @@ func landingPage() gin.HandlerFunc {
   -H 'Content-Type: application/json' \
   -d '{"fen":"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1","depth":12}' \
   /api/v1/analyze</code></pre>
+            <div style="margin-top:10px"><span class="k">GET</span> /api/v1/analyze/stream?san=e4%20e5&amp;depth=16</div>
           </div>
         </div>
       </div>
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/app"
+)
+
+// @Summary Metrics
+// @Description Component state in the Prometheus text format: numbers become gauges, booleans 0/1 and enum-like strings a {value="..."} series set to 1.
+// @Tags Health
+// @Produce plain
+// @Success 200 {string} string "metrics"
+// @Router /metrics [get]
+func metricsHandler(svc *app.ChessService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var lines []string
+		for name, status := range svc.Status() {
+			// Round-trip through JSON so every component is reported by its
+			// JSON field names.
+			data, err := json.Marshal(status)
+			if err != nil {
+				continue
+			}
+			var tree any
+			if err := json.Unmarshal(data, &tree); err != nil {
+				continue
+			}
+			lines = appendMetrics(lines, "stockfish_"+metricName(name), tree)
+		}
+		sort.Strings(lines)
+		c.Data(http.StatusOK, "text/plain; version=0.0.4", []byte(strings.Join(lines, "\n")+"\n"))
+	}
+}
+
+var enumValue = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+func appendMetrics(lines []string, prefix string, v any) []string {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			lines = appendMetrics(lines, prefix+"_"+metricName(k), child)
+		}
+	case float64:
+		lines = append(lines, fmt.Sprintf("%s %g", prefix, v))
+	case bool:
+		n := 0
+		if v {
+			n = 1
+		}
+		lines = append(lines, fmt.Sprintf("%s %d", prefix, n))
+	case string:
+		// Free text such as error messages would make a new series per value.
+		if enumValue.MatchString(v) {
+			lines = append(lines, fmt.Sprintf("%s{value=%q} 1", prefix, v))
+		}
+	}
+	return lines
+}
+
+// metricName turns "consecutiveFailures" into "consecutive_failures".
+func metricName(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/app"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+type reviewRequest struct {
+	PGN      string `json:"pgn" example:"1. e4 e5 2. Nf3 Nc6 3. Bb5 a6"`
+	Depth    int    `json:"depth,omitempty" example:"14"`
+	MoveTime int    `json:"movetime,omitempty"`
+	Nodes    int    `json:"nodes,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+	Priority string `json:"priority,omitempty" enums:"interactive,normal,batch"`
+}
+
+func (r reviewRequest) toPort() (ports.AnalyzeRequest, error) {
+	pgn := strings.TrimSpace(r.PGN)
+	if pgn == "" {
+		return ports.AnalyzeRequest{}, &ports.InputError{Field: "pgn", Err: errors.New("pgn required")}
+	}
+	return ports.AnalyzeRequest{
+		PGN:      pgn,
+		Limits:   ports.SearchLimits{Depth: r.Depth, MoveTime: r.MoveTime, Nodes: r.Nodes},
+		Profile:  r.Profile,
+		Priority: strings.TrimSpace(r.Priority),
+	}, nil
+}
+
+// @Summary Review game
+// @Description Analyzes every position of a PGN's main line and grades each move against the engine's choice before it: centipawn loss, lost winning chances, accuracy and a classification (best, excellent, good, inaccuracy, mistake, blunder, missedMate).
+// @Description Each side gets its accuracy, average centipawn loss (acpl) and a count per classification. Limits apply to every position, and the positions share REVIEW_TIMEOUT.
+// @Tags Analysis
+// @Accept json
+// @Produce json
+// @Param request body reviewRequest true "Game to review"
+// @Success 200 {object} app.GameReview
+// @Failure 400 {object} errorResponse "invalid_input"
+// @Failure 422 {object} errorResponse "illegal_move, with line and column in details"
+// @Failure 429 {object} errorResponse "overloaded"
+// @Failure 502 {object} errorResponse "engine_error"
+// @Failure 503 {object} errorResponse "engine_unavailable or overloaded"
+// @Failure 504 {object} errorResponse "timeout"
+// @Router /games/review [post]
+func reviewHandler(svc *app.ChessService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req reviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, errInvalidJSON)
+			return
+		}
+
+		reviewReq, err := req.toPort()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		review, err := svc.Review(c.Request.Context(), reviewReq)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, review)
+	}
+}
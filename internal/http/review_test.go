@@ -0,0 +1,75 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/app"
+)
+
+func TestReviewHandler(t *testing.T) {
+	r := newTestRouter(t)
+
+	var review app.GameReview
+	w := do(t, r, http.MethodPost, "/api/v1/games/review", map[string]any{"pgn": "1. e4 e5 2. Nf3 Nc6"}, &review)
+	if w.Code != http.StatusOK || len(review.Moves) != 4 || review.White.Moves != 2 {
+		t.Fatalf("status %d, review = %+v", w.Code, review)
+	}
+
+	var body errorResponse
+	w = do(t, r, http.MethodPost, "/api/v1/games/review", map[string]any{"pgn": "  "}, &body)
+	if w.Code != http.StatusBadRequest || body.Code != codeInvalidInput || body.Details["field"] != "pgn" {
+		t.Errorf("empty pgn: status %d, body = %+v", w.Code, body)
+	}
+
+	body = errorResponse{}
+	w = do(t, r, http.MethodPost, "/api/v1/games/review", map[string]any{"pgn": "1. e4 e5\n2. Nf3 Ke7 3. Ke3"}, &body)
+	if w.Code != http.StatusUnprocessableEntity || body.Code != codeIllegalMove {
+		t.Fatalf("illegal move: status %d, body = %+v", w.Code, body)
+	}
+	if body.Details["line"] != float64(2) || body.Details["column"] != float64(15) || body.Details["move"] != "Ke3" {
+		t.Errorf("illegal move details = %v", body.Details)
+	}
+}
+
+func TestReviewHandler_FinishedGames(t *testing.T) {
+	r := newTestRouter(t)
+
+	tests := []struct {
+		name      string
+		pgn       string
+		after     string
+		class     string
+		maxLossCp int
+	}{
+		{"checkmate", "1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7#", "mate 0", app.MoveBest, 0},
+		{"stalemate", "1. e3 a5 2. Qh5 Ra6 3. Qxa5 h5 4. h4 Rah6 5. Qxc7 f6 6. Qxd7+ Kf7 7. Qxb7 Qd3 8. Qxb8 Qh7 9. Qxc8 Kg6 10. Qe6", "cp 0", app.MoveBlunder, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var review app.GameReview
+			w := do(t, r, http.MethodPost, "/api/v1/games/review", map[string]any{"pgn": tt.pgn}, &review)
+			if w.Code != http.StatusOK || len(review.Moves) == 0 {
+				t.Fatalf("status %d, review = %+v", w.Code, review)
+			}
+			last := review.Moves[len(review.Moves)-1]
+			if after := evaluation(last.EvaluationAfterCp, last.EvaluationAfterMate); after != tt.after {
+				t.Errorf("evaluation after the last move = %q, want %q", after, tt.after)
+			}
+			if last.Classification != tt.class || last.CentipawnLoss > tt.maxLossCp {
+				t.Errorf("last move = %+v, want %s", last, tt.class)
+			}
+		})
+	}
+}
+
+func evaluation(cp, mate *int) string {
+	switch {
+	case mate != nil:
+		return fmt.Sprintf("mate %d", *mate)
+	case cp != nil:
+		return fmt.Sprintf("cp %d", *cp)
+	}
+	return "none"
+}
@@ -6,12 +6,26 @@ import (
 	"github.com/aminammar1/stockfish-go-ec2/internal/app"
 )
 
-func RegisterRoutes(r *gin.Engine, svc *app.ChessService) {
+func RegisterRoutes(r *gin.Engine, svc *app.ChessService, sessions *app.SessionManager, jobs *app.JobManager) {
 	r.GET("/", landingPage())
+	r.GET("/metrics", metricsHandler(svc))
 
 	v1 := r.Group("/api/v1")
 	{
 		v1.GET("/health", healthHandler(svc))
 		v1.POST("/analyze", analyzeHandler(svc))
+		v1.GET("/analyze/stream", analyzeStreamHandler(svc))
+		v1.POST("/analyze/stream", analyzeStreamHandler(svc))
+		v1.POST("/games/review", reviewHandler(svc))
+
+		v1.POST("/sessions", startSessionHandler(sessions))
+		v1.GET("/sessions", listSessionsHandler(sessions))
+		v1.GET("/sessions/:id", getSessionHandler(sessions))
+		v1.POST("/sessions/:id/position", sessionPositionHandler(sessions))
+		v1.DELETE("/sessions/:id", stopSessionHandler(sessions))
+
+		v1.POST("/jobs", submitJobHandler(jobs))
+		v1.GET("/jobs/:id", getJobHandler(jobs))
+		v1.DELETE("/jobs/:id", cancelJobHandler(jobs))
 	}
 }
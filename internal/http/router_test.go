@@ -0,0 +1,75 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/adapters/stockfish_fake"
+	"github.com/aminammar1/stockfish-go-ec2/internal/app"
+	"github.com/aminammar1/stockfish-go-ec2/internal/config"
+)
+
+// newTestRouter serves the API from the in-process fake engine.
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := config.Config{
+		EnginePoolSize:     1,
+		EngineMaxSearches:  100,
+		EngineReadyTimeout: time.Second,
+		AnalysisDepth:      2,
+		MaxDepth:           10,
+		MaxMultiPV:         3,
+		MaxPlies:           300,
+		MaxSessions:        1,
+	}
+	adapter, err := stockfish_fake.NewAdapter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { adapter.Close() })
+
+	svc := app.NewChessService(adapter, nil)
+	sessions := app.NewSessionManager(adapter, time.Minute, cfg.MaxSessions)
+	t.Cleanup(sessions.Close)
+	jobs := app.NewJobManager(svc, 1, 10, time.Minute)
+	t.Cleanup(jobs.Close)
+
+	r := gin.New()
+	RegisterRoutes(r, svc, sessions, jobs)
+	return r
+}
+
+// do sends a JSON request and decodes the JSON response into out, if set.
+func do(t *testing.T, r http.Handler, method, path string, body any, out any) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := httptest.NewRequest(method, path, reader).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w
+}
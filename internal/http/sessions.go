@@ -0,0 +1,131 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/app"
+)
+
+// @Summary Start infinite analysis
+// @Description Starts "go infinite" on a leased engine for the given position. The session expires when idle.
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Param request body analyzeRequest true "Position (exactly one of fen|pgn|uci|san); search limits are ignored"
+// @Success 201 {object} app.SessionInfo
+// @Failure 400 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 422 {object} errorResponse
+// @Failure 502 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Router /sessions [post]
+func startSessionHandler(sessions *app.SessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req analyzeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, errInvalidJSON)
+			return
+		}
+		analyzeReq, err := req.toPort()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		info, err := sessions.Start(c.Request.Context(), analyzeReq)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, info)
+	}
+}
+
+// @Summary List analysis sessions
+// @Tags Sessions
+// @Produce json
+// @Success 200 {array} app.SessionInfo
+// @Router /sessions [get]
+func listSessionsHandler(sessions *app.SessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"sessions": sessions.List()})
+	}
+}
+
+// @Summary Read analysis session
+// @Description Returns the latest analysis of the session and keeps it alive.
+// @Tags Sessions
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} app.SessionInfo
+// @Failure 404 {object} errorResponse
+// @Router /sessions/{id} [get]
+func getSessionHandler(sessions *app.SessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info, err := sessions.Get(c.Param("id"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+// @Summary Update session position
+// @Description Stops the current search and restarts it on the new position without restarting the engine.
+// @Description If the engine fails to restart, the session is closed and removed (410 session_closed).
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Param id path string true "Session ID"
+// @Param request body analyzeRequest true "Position (exactly one of fen|pgn|uci|san)"
+// @Success 200 {object} app.SessionInfo
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 410 {object} errorResponse "session_closed"
+// @Router /sessions/{id}/position [post]
+func sessionPositionHandler(sessions *app.SessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req analyzeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, errInvalidJSON)
+			return
+		}
+		analyzeReq, err := req.toPort()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		info, err := sessions.SetPosition(c.Request.Context(), c.Param("id"), analyzeReq)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+// @Summary Stop analysis session
+// @Description Stops the search, releases the engine and returns the final analysis.
+// @Tags Sessions
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} app.SessionInfo
+// @Failure 404 {object} errorResponse
+// @Router /sessions/{id} [delete]
+func stopSessionHandler(sessions *app.SessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info, err := sessions.Stop(c.Param("id"))
+		if errors.Is(err, app.ErrSessionNotFound) {
+			writeError(c, err)
+			return
+		}
+		// Any other error came from closing the engine; the session is gone
+		// either way, so report its final analysis.
+		c.JSON(http.StatusOK, info)
+	}
+}
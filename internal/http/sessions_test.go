@@ -0,0 +1,66 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/app"
+)
+
+func TestSessionHandlers(t *testing.T) {
+	r := newTestRouter(t)
+
+	var started app.SessionInfo
+	w := do(t, r, http.MethodPost, "/api/v1/sessions", map[string]any{"uci": "e2e4"}, &started)
+	if w.Code != http.StatusCreated || started.ID == "" {
+		t.Fatalf("start: status %d, %+v", w.Code, started)
+	}
+
+	// The test router allows a single session.
+	var body errorResponse
+	w = do(t, r, http.MethodPost, "/api/v1/sessions", map[string]any{"uci": "e2e4"}, &body)
+	if w.Code != http.StatusTooManyRequests || body.Code != codeOverloaded {
+		t.Errorf("second session: status %d, %+v", w.Code, body)
+	}
+
+	// Searches still have their own engines while the session runs.
+	w = do(t, r, http.MethodPost, "/api/v1/analyze", map[string]any{"uci": "e2e4 e7e5"}, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("analyze during a session: status %d, %s", w.Code, w.Body)
+	}
+
+	var got app.SessionInfo
+	w = do(t, r, http.MethodGet, "/api/v1/sessions/"+started.ID, nil, &got)
+	if w.Code != http.StatusOK || got.ID != started.ID || got.Analysis == nil {
+		t.Errorf("get: status %d, %+v", w.Code, got)
+	}
+
+	const fen = "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"
+	var moved app.SessionInfo
+	w = do(t, r, http.MethodPost, "/api/v1/sessions/"+started.ID+"/position", map[string]any{"fen": fen}, &moved)
+	if w.Code != http.StatusOK || moved.PositionFEN != fen {
+		t.Errorf("position: status %d, %+v", w.Code, moved)
+	}
+
+	body = errorResponse{}
+	w = do(t, r, http.MethodPost, "/api/v1/sessions/"+started.ID+"/position", map[string]any{"uci": "e2e5"}, &body)
+	if w.Code != http.StatusUnprocessableEntity || body.Code != codeIllegalMove {
+		t.Errorf("illegal position: status %d, %+v", w.Code, body)
+	}
+
+	var list struct{ Sessions []app.SessionInfo }
+	w = do(t, r, http.MethodGet, "/api/v1/sessions", nil, &list)
+	if w.Code != http.StatusOK || len(list.Sessions) != 1 {
+		t.Errorf("list: status %d, %+v", w.Code, list)
+	}
+
+	w = do(t, r, http.MethodDelete, "/api/v1/sessions/"+started.ID, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("delete: status %d, %s", w.Code, w.Body)
+	}
+	body = errorResponse{}
+	w = do(t, r, http.MethodGet, "/api/v1/sessions/"+started.ID, nil, &body)
+	if w.Code != http.StatusNotFound || body.Code != codeNotFound {
+		t.Errorf("get after delete: status %d, %+v", w.Code, body)
+	}
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/app"
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+type streamEvent struct {
+	Event string `json:"event"`
+	Data  any    `json:"data"`
+}
+
+// runStream starts the analysis and emits an "info" event per engine
+// update followed by a final "bestmove" or "error" event. The channel is
+// closed after the final event; callers must drain it.
+func runStream(ctx context.Context, svc *app.ChessService, req ports.AnalyzeRequest) <-chan streamEvent {
+	events := make(chan streamEvent, 64)
+	go func() {
+		defer close(events)
+		req.Progress = func(u ports.AnalysisUpdate) {
+			// Drop intermediate updates rather than stall the engine on a slow client.
+			select {
+			case events <- streamEvent{Event: "info", Data: u}:
+			default:
+			}
+		}
+		result, err := svc.Analyze(ctx, req)
+		if err != nil {
+			events <- errorEvent(err)
+			return
+		}
+		events <- streamEvent{Event: "bestmove", Data: result}
+	}()
+	return events
+}
+
+func errorEvent(err error) streamEvent {
+	_, body, _ := classify(err)
+	return streamEvent{Event: "error", Data: body}
+}
+
+// @Summary Stream analysis
+// @Description Same input as /analyze (query parameters for GET, JSON body for POST), answered as Server-Sent Events:
+// @Description "info" for each engine update (depth, score, PV in SAN, nodes, nps) and a final "bestmove" carrying the full result, or "error".
+// @Description A GET with a WebSocket upgrade streams the same events as JSON messages {"event","data"}; the request may then be sent as the first message.
+// @Tags Analysis
+// @Accept json
+// @Produce text/event-stream
+// @Param request body analyzeRequest false "Analyze request (POST only)"
+// @Success 200 {string} string "event stream"
+// @Failure 400 {object} errorResponse
+// @Router /analyze/stream [get]
+// @Router /analyze/stream [post]
+func analyzeStreamHandler(svc *app.ChessService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet && strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			serveAnalyzeWebSocket(c, svc)
+			return
+		}
+
+		var req analyzeRequest
+		if c.Request.Method == http.MethodGet {
+			if err := c.ShouldBindQuery(&req); err != nil {
+				writeError(c, errInvalidQuery)
+				return
+			}
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, errInvalidJSON)
+			return
+		}
+
+		analyzeReq, err := req.toPort()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		for ev := range runStream(c.Request.Context(), svc, analyzeReq) {
+			c.SSEvent(ev.Event, ev.Data)
+			c.Writer.Flush()
+		}
+	}
+}
+
+func serveAnalyzeWebSocket(c *gin.Context, svc *app.ChessService) {
+	var query analyzeRequest
+	_ = c.ShouldBindQuery(&query)
+
+	server := websocket.Server{
+		// The API is unauthenticated, so browsers from any origin may connect.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			req := query
+			if req.FEN == "" && req.PGN == "" && req.UCI == "" && req.SAN == "" {
+				if err := websocket.JSON.Receive(ws, &req); err != nil {
+					websocket.JSON.Send(ws, errorEvent(errInvalidJSON))
+					return
+				}
+			}
+			analyzeReq, err := req.toPort()
+			if err != nil {
+				websocket.JSON.Send(ws, errorEvent(err))
+				return
+			}
+
+			// A hijacked connection does not cancel the request context, so
+			// watch the socket for the client going away.
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				var discard []byte
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+				cancel()
+			}()
+
+			for ev := range runStream(ctx, svc, analyzeReq) {
+				websocket.JSON.Send(ws, ev)
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,29 @@
+package pgn
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+var ErrMalformedFEN = errors.New("malformed fen")
+
+// fenPattern is stricter than chess.FEN, which keeps a castling field such
+// as "" or "-K" verbatim and would hand it on to the engine.
+var fenPattern = regexp.MustCompile(`^[1-8pnbrqkPNBRQK]+(?:/[1-8pnbrqkPNBRQK]+){7} [wb] (?:-|KQ?k?q?|Qk?q?|kq?|q) (?:-|[a-h][36]) [0-9]{1,4} [0-9]{1,4}$`)
+
+// ParseFEN returns the position a FEN describes. Surrounding whitespace is
+// ignored; anything else that is not strict FEN is refused.
+func ParseFEN(fen string) (*chess.Position, error) {
+	fen = strings.TrimSpace(fen)
+	if !fenPattern.MatchString(fen) {
+		return nil, ErrMalformedFEN
+	}
+	opt, err := chess.FEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	return chess.NewGame(opt).Position(), nil
+}
@@ -0,0 +1,219 @@
+package pgn
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokTagOpen
+	tokTagClose
+	tokString
+	tokSymbol
+	tokMoveNumber
+	tokComment
+	tokNAG
+	tokVariationOpen
+	tokVariationClose
+	tokResult
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	line   int
+	column int
+}
+
+// lexer splits PGN text into tokens, tracking 1-based line and column
+// numbers (columns count characters, not bytes).
+type lexer struct {
+	src    string
+	off    int
+	line   int
+	column int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, line: 1, column: 1}
+}
+
+func (l *lexer) errorf(line, column int, format string, args ...any) *Error {
+	return &Error{Line: line, Column: column, Err: fmt.Errorf(format, args...)}
+}
+
+func (l *lexer) peek() rune {
+	if l.off >= len(l.src) {
+		return -1
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.off:])
+	return r
+}
+
+func (l *lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.src[l.off:])
+	l.off += size
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return r
+}
+
+func (l *lexer) next() (token, error) {
+	for {
+		r := l.peek()
+		switch {
+		case r == -1:
+			return token{kind: tokEOF, line: l.line, column: l.column}, nil
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			l.advance()
+		case r == '%' && l.column == 1:
+			// Escape mechanism: the whole line is ignored.
+			l.skipLine()
+		default:
+			return l.scan()
+		}
+	}
+}
+
+func (l *lexer) skipLine() {
+	for r := l.peek(); r != -1 && r != '\n'; r = l.peek() {
+		l.advance()
+	}
+}
+
+func (l *lexer) scan() (token, error) {
+	line, column := l.line, l.column
+	tok := func(kind tokenKind, text string) (token, error) {
+		return token{kind: kind, text: text, line: line, column: column}, nil
+	}
+
+	switch r := l.peek(); {
+	case r == '[':
+		l.advance()
+		return tok(tokTagOpen, "[")
+	case r == ']':
+		l.advance()
+		return tok(tokTagClose, "]")
+	case r == '(':
+		l.advance()
+		return tok(tokVariationOpen, "(")
+	case r == ')':
+		l.advance()
+		return tok(tokVariationClose, ")")
+	case r == '"':
+		return l.scanString(line, column)
+	case r == '{':
+		l.advance()
+		start := l.off
+		for {
+			switch l.peek() {
+			case -1:
+				return token{}, l.errorf(line, column, "unterminated comment")
+			case '}':
+				text := l.src[start:l.off]
+				l.advance()
+				return tok(tokComment, strings.TrimSpace(text))
+			default:
+				l.advance()
+			}
+		}
+	case r == ';':
+		l.advance()
+		start := l.off
+		l.skipLine()
+		return tok(tokComment, strings.TrimSpace(l.src[start:l.off]))
+	case r == '$':
+		l.advance()
+		start := l.off
+		for isDigit(l.peek()) {
+			l.advance()
+		}
+		if l.off == start {
+			return token{}, l.errorf(line, column, "NAG without a number")
+		}
+		return tok(tokNAG, l.src[start:l.off])
+	case r == '*':
+		l.advance()
+		return tok(tokResult, "*")
+	case r == '.':
+		for l.peek() == '.' {
+			l.advance()
+		}
+		return l.next()
+	case isSymbolStart(r):
+		start := l.off
+		for isSymbolChar(l.peek()) {
+			l.advance()
+		}
+		// Suffix annotations belong to the move they follow.
+		for r := l.peek(); r == '!' || r == '?'; r = l.peek() {
+			l.advance()
+		}
+		text := l.src[start:l.off]
+		switch {
+		case text == "1-0" || text == "0-1" || text == "1/2-1/2":
+			return tok(tokResult, text)
+		case isMoveNumber(text):
+			for l.peek() == '.' {
+				l.advance()
+			}
+			return tok(tokMoveNumber, text)
+		}
+		return tok(tokSymbol, text)
+	default:
+		l.advance()
+		return token{}, l.errorf(line, column, "unexpected character %q", r)
+	}
+}
+
+func (l *lexer) scanString(line, column int) (token, error) {
+	l.advance()
+	var b strings.Builder
+	for {
+		switch r := l.peek(); r {
+		case -1, '\n':
+			return token{}, l.errorf(line, column, "unterminated string")
+		case '"':
+			l.advance()
+			return token{kind: tokString, text: b.String(), line: line, column: column}, nil
+		case '\\':
+			l.advance()
+			if next := l.peek(); next == '"' || next == '\\' {
+				b.WriteRune(l.advance())
+			} else {
+				b.WriteRune('\\')
+			}
+		default:
+			b.WriteRune(l.advance())
+		}
+	}
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isSymbolStart(r rune) bool {
+	return isDigit(r) || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isSymbolChar(r rune) bool {
+	return isSymbolStart(r) || strings.ContainsRune("_+#=:-/", r)
+}
+
+func isMoveNumber(text string) bool {
+	for _, r := range text {
+		if !isDigit(r) {
+			return false
+		}
+	}
+	return true
+}
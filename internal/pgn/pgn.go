@@ -0,0 +1,304 @@
+// Package pgn reads games in Portable Game Notation. Unlike chess.PGN it
+// keeps the tag pairs, comments, NAGs and variations, starts from the FEN
+// tag when one is given and reports errors with their line and column.
+package pgn
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// Error is a problem at a given place in the PGN text. Illegal moves wrap a
+// *ports.IllegalMoveError.
+type Error struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+type Tag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Move is one move as written, with what the PGN says about it. Variations
+// are alternatives to this move, played from the position before it.
+type Move struct {
+	Move       *chess.Move
+	SAN        string
+	Line       int
+	Column     int
+	NAGs       []int
+	Before     []string
+	Comments   []string
+	Variations [][]*Move
+}
+
+type Game struct {
+	Tags   []Tag
+	Start  *chess.Position
+	Moves  []*Move
+	Result string
+	// Comments are those no move owns: after the result, or in a game
+	// without moves.
+	Comments []string
+}
+
+// Tag returns the value of the named tag, or "" if the game has none.
+func (g *Game) Tag(name string) string {
+	for _, t := range g.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// MainLine returns the moves of the main line.
+func (g *Game) MainLine() []*chess.Move {
+	moves := make([]*chess.Move, len(g.Moves))
+	for i, m := range g.Moves {
+		moves[i] = m.Move
+	}
+	return moves
+}
+
+var sanPattern = regexp.MustCompile(`^(?:[NBRQK]?[a-h]?[1-8]?x?[a-h][1-8](?:=?[NBRQ])?|O-O(?:-O)?|0-0(?:-0)?)[+#]?$`)
+
+// suffixNAGs maps move suffix annotations to their NAG numbers.
+var suffixNAGs = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// Parse reads a single game.
+func Parse(text string) (*Game, error) {
+	p := &parser{lex: newLexer(text)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	g := &Game{Start: chess.StartingPosition()}
+	if err := p.parseTags(g); err != nil {
+		return nil, err
+	}
+
+	moves, comments, err := p.parseLine(g.Start, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	g.Moves, g.Comments = moves, comments
+
+	if p.tok.kind == tokResult {
+		g.Result = p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	// Comments may still follow the result.
+	for p.tok.kind == tokComment {
+		g.Comments = append(g.Comments, p.tok.text)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	switch p.tok.kind {
+	case tokEOF:
+		return g, nil
+	case tokTagOpen:
+		return nil, p.errorf("only one game per request is supported")
+	default:
+		return nil, p.errorf("unexpected %q after the game", p.tok.text)
+	}
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) *Error {
+	return p.lex.errorf(p.tok.line, p.tok.column, format, args...)
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.tok
+	if tok.kind != kind {
+		return tok, p.errorf("expected %s", what)
+	}
+	return tok, p.advance()
+}
+
+func (p *parser) parseTags(g *Game) error {
+	for p.tok.kind == tokTagOpen {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		name, err := p.expect(tokSymbol, "tag name")
+		if err != nil {
+			return err
+		}
+		value, err := p.expect(tokString, "quoted tag value")
+		if err != nil {
+			return err
+		}
+		if _, err := p.expect(tokTagClose, `"]"`); err != nil {
+			return err
+		}
+		// The FEN tag sets the start position whether or not SetUp says so.
+		if name.text == "FEN" {
+			start, err := ParseFEN(value.text)
+			if err != nil {
+				return &Error{Line: value.line, Column: value.column, Err: fmt.Errorf("FEN tag: %w", err)}
+			}
+			g.Start = start
+		}
+		g.Tags = append(g.Tags, Tag{Name: name.text, Value: value.text})
+	}
+	return nil
+}
+
+// maxVariationDepth bounds how deeply variations may nest, which also
+// bounds the recursion of parseLine.
+const maxVariationDepth = 32
+
+// parseLine reads moves played from pos until the end of the line: a
+// closing parenthesis inside a variation, the result or the end of the
+// text in the main line. ply is the number of half-moves played before
+// pos since the start of the game. Comments that no move can own are
+// returned.
+func (p *parser) parseLine(pos *chess.Position, depth, ply int) ([]*Move, []string, error) {
+	var moves []*Move
+	var pending []string
+	// before is the position the last move was played from, where its
+	// variations start.
+	var before *chess.Position
+
+	for {
+		switch p.tok.kind {
+		case tokEOF, tokResult:
+			if depth > 0 {
+				return nil, nil, p.errorf("unterminated variation")
+			}
+			return moves, pending, nil
+
+		case tokVariationClose:
+			if depth == 0 {
+				return nil, nil, p.errorf("unmatched \")\"")
+			}
+			return moves, pending, nil
+
+		case tokVariationOpen:
+			if len(moves) == 0 {
+				return nil, nil, p.errorf("variation before any move")
+			}
+			if depth >= maxVariationDepth {
+				return nil, nil, p.errorf("variations nested more than %d deep", maxVariationDepth)
+			}
+			if err := p.advance(); err != nil {
+				return nil, nil, err
+			}
+			variation, trailing, err := p.parseLine(before, depth+1, ply+len(moves)-1)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(variation) > 0 {
+				last := variation[len(variation)-1]
+				last.Comments = append(last.Comments, trailing...)
+			}
+			last := moves[len(moves)-1]
+			last.Variations = append(last.Variations, variation)
+			if err := p.advance(); err != nil {
+				return nil, nil, err
+			}
+
+		case tokComment:
+			if len(moves) > 0 && len(pending) == 0 {
+				last := moves[len(moves)-1]
+				last.Comments = append(last.Comments, p.tok.text)
+			} else {
+				pending = append(pending, p.tok.text)
+			}
+			if err := p.advance(); err != nil {
+				return nil, nil, err
+			}
+
+		case tokNAG:
+			if len(moves) == 0 {
+				return nil, nil, p.errorf("NAG before any move")
+			}
+			n, err := strconv.Atoi(p.tok.text)
+			if err != nil || n > 255 {
+				return nil, nil, p.errorf("invalid NAG $%s", p.tok.text)
+			}
+			last := moves[len(moves)-1]
+			last.NAGs = append(last.NAGs, n)
+			if err := p.advance(); err != nil {
+				return nil, nil, err
+			}
+
+		case tokMoveNumber:
+			if err := p.advance(); err != nil {
+				return nil, nil, err
+			}
+
+		case tokSymbol:
+			m, err := p.parseMove(pos, ply+len(moves), depth > 0)
+			if err != nil {
+				return nil, nil, err
+			}
+			m.Before, pending = pending, nil
+			moves = append(moves, m)
+			before, pos = pos, pos.Update(m.Move)
+			if err := p.advance(); err != nil {
+				return nil, nil, err
+			}
+
+		default:
+			return nil, nil, p.errorf("unexpected %q", p.tok.text)
+		}
+	}
+}
+
+func (p *parser) parseMove(pos *chess.Position, index int, variation bool) (*Move, error) {
+	text := p.tok.text
+	san := strings.TrimRight(text, "!?")
+	m := &Move{SAN: san, Line: p.tok.line, Column: p.tok.column}
+	if suffix := text[len(san):]; suffix != "" {
+		n, ok := suffixNAGs[suffix]
+		if !ok {
+			return nil, p.errorf("invalid move annotation %q", suffix)
+		}
+		m.NAGs = append(m.NAGs, n)
+	}
+
+	if !sanPattern.MatchString(san) {
+		return nil, p.errorf("malformed move %q", san)
+	}
+	decoded, err := chess.AlgebraicNotation{}.Decode(pos, strings.ReplaceAll(san, "0", "O"))
+	if err != nil {
+		return nil, &Error{Line: m.Line, Column: m.Column, Err: &ports.IllegalMoveError{Field: "pgn", Index: index, Variation: variation, Move: san, FEN: pos.String()}}
+	}
+	m.Move = decoded
+	return m, nil
+}
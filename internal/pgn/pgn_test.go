@@ -0,0 +1,118 @@
+package pgn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+func TestParse_Annotations(t *testing.T) {
+	text := `[Event "Casual \"blitz\""]
+[White "Müller"]
+[Result "1-0"]
+
+{Opening comment} 1. e4 e5!? $14 {A comment with ) and ( inside}
+2. Nf3 (2. f4 exf4 (2... d5 3. exd5) 3. Nf3) (2. Bc4) Nc6 ; rest of line
+% escaped line e5e4
+3. Bb5 a6 4. O-O 1-0 {after the result}`
+
+	g, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Tag("Event") != `Casual "blitz"` || g.Tag("White") != "Müller" || len(g.Tags) != 3 {
+		t.Errorf("tags = %+v", g.Tags)
+	}
+	if g.Result != "1-0" || len(g.Comments) != 1 || g.Comments[0] != "after the result" {
+		t.Errorf("result = %q, comments = %q", g.Result, g.Comments)
+	}
+	if len(g.Moves) != 7 {
+		t.Fatalf("main line has %d moves", len(g.Moves))
+	}
+
+	e4, e5, nf3, nc6, castles := g.Moves[0], g.Moves[1], g.Moves[2], g.Moves[3], g.Moves[6]
+	if len(e4.Before) != 1 || e4.Before[0] != "Opening comment" {
+		t.Errorf("e4 before = %q", e4.Before)
+	}
+	if len(e5.NAGs) != 2 || e5.NAGs[0] != 5 || e5.NAGs[1] != 14 {
+		t.Errorf("e5 nags = %v", e5.NAGs)
+	}
+	if len(e5.Comments) != 1 || e5.Comments[0] != "A comment with ) and ( inside" {
+		t.Errorf("e5 comments = %q", e5.Comments)
+	}
+	if len(nf3.Variations) != 2 || len(nf3.Variations[0]) != 3 || len(nf3.Variations[1]) != 1 {
+		t.Fatalf("nf3 variations = %+v", nf3.Variations)
+	}
+	exf4 := nf3.Variations[0][1]
+	if len(exf4.Variations) != 1 || exf4.Variations[0][0].SAN != "d5" {
+		t.Errorf("nested variation = %+v", exf4.Variations)
+	}
+	if len(nc6.Comments) != 1 || nc6.Comments[0] != "rest of line" {
+		t.Errorf("nc6 comments = %q", nc6.Comments)
+	}
+	if castles.SAN != "O-O" || castles.Line != 8 || castles.Column != 14 {
+		t.Errorf("castles = %+v", castles)
+	}
+}
+
+func TestParse_FENTag(t *testing.T) {
+	g, err := Parse(`[SetUp "1"]
+[FEN "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"]
+
+1. e4 Kd7 *`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := g.Start.String(); got != "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1" {
+		t.Errorf("start = %q", got)
+	}
+	if len(g.MainLine()) != 2 || g.Result != "*" {
+		t.Errorf("moves = %d, result = %q", len(g.MainLine()), g.Result)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		line, column int
+		msg          string
+	}{
+		{"illegal move", "1. e4 e5\n2. Ke3", 2, 4, "illegal move"},
+		{"illegal move in variation", "1. e4 (1. Nf6) e5", 1, 11, "illegal move"},
+		{"malformed move", "1. e4 Zz9", 1, 7, "malformed move"},
+		{"unterminated variation", "1. e4 (1. d4 d5", 1, 16, "unterminated variation"},
+		{"unmatched parenthesis", "1. e4 e5 )", 1, 10, "unmatched"},
+		{"unterminated comment", "1. e4 {never closed", 1, 7, "unterminated comment"},
+		{"bad FEN tag", "[FEN \"8/8/8/8/8/8/8/K6k w  - 0 1\"]\n1. Kb2", 1, 6, "FEN tag"},
+		{"second game", "1. e4 *\n\n[Event \"?\"]\n1. d4 *", 3, 1, "one game"},
+		{"stray character", "1. e4 <", 1, 7, "unexpected character"},
+		{"nested too deep", "1. e4 " + strings.Repeat("(1. d4 ", maxVariationDepth+1), 1, 7 + 7*maxVariationDepth, "nested more than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.text)
+			var perr *Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("err = %v", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column || !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("err = %v, want line %d, column %d, %q", err, tt.line, tt.column, tt.msg)
+			}
+		})
+	}
+
+	_, err := Parse("1. e4 e5 2. Ke3")
+	var illegal *ports.IllegalMoveError
+	if !errors.As(err, &illegal) || illegal.Index != 2 || illegal.Move != "Ke3" || illegal.Variation {
+		t.Errorf("illegal move err = %#v", err)
+	}
+
+	// Variation moves are indexed from the start of the game.
+	_, err = Parse("1. e4 e5 2. Nf3 Nc6 (2... d6 3. d4 (3. Ke3))")
+	if !errors.As(err, &illegal) || illegal.Index != 4 || illegal.Move != "Ke3" || !illegal.Variation {
+		t.Errorf("illegal variation move err = %#v", err)
+	}
+}
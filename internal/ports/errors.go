@@ -0,0 +1,132 @@
+package ports
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidInput matches every InputError.
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrIllegalMove matches every IllegalMoveError.
+	ErrIllegalMove = errors.New("illegal move")
+	// ErrTimeout reports a search that ran past the caller's deadline.
+	ErrTimeout = errors.New("analysis timed out")
+	// ErrSessionClosed reports an analysis session that has ended, for
+	// instance because its engine failed, and cannot be used again.
+	ErrSessionClosed = errors.New("analysis session closed")
+)
+
+// InputError reports a request that cannot be run as given, such as a
+// malformed FEN or PGN or an unsupported option. Field names the offending
+// request field.
+type InputError struct {
+	Field string
+	Err   error
+}
+
+func (e *InputError) Error() string {
+	if e.Field == "" {
+		return e.Err.Error()
+	}
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+func (e *InputError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// IllegalMoveError reports a well-formed move that cannot be played. Index
+// is the zero-based position of the move in the submitted list and FEN the
+// position it was tried in. For a move in a PGN variation, Variation is
+// set and Index counts the half-moves from the start of the game.
+type IllegalMoveError struct {
+	Field     string
+	Index     int
+	Variation bool
+	Move      string
+	FEN       string
+}
+
+func (e *IllegalMoveError) Error() string {
+	if e.Variation {
+		return fmt.Sprintf("%s: illegal move %q at index %d in a variation", e.Field, e.Move, e.Index)
+	}
+	return fmt.Sprintf("%s: illegal move %q at index %d", e.Field, e.Move, e.Index)
+}
+
+func (e *IllegalMoveError) Is(target error) bool {
+	return target == ErrIllegalMove
+}
+
+// ErrOverloaded matches every OverloadedError.
+var ErrOverloaded = errors.New("engine overloaded")
+
+// OverloadedError reports a search refused by admission control, either
+// because the wait queue was full or because it waited too long in it.
+type OverloadedError struct {
+	QueueFull  bool
+	Waited     time.Duration
+	RetryAfter time.Duration
+}
+
+func (e *OverloadedError) Error() string {
+	if e.QueueFull {
+		return "engine overloaded: wait queue is full"
+	}
+	return fmt.Sprintf("engine overloaded: no engine free within %s", e.Waited)
+}
+
+func (e *OverloadedError) Is(target error) bool {
+	return target == ErrOverloaded
+}
+
+// ErrPermanent matches failures that retrying cannot fix, such as a
+// changed host key or a missing engine binary: only an operator can.
+var ErrPermanent = errors.New("permanent failure")
+
+// PermanentError marks Err as a failure that retrying cannot fix.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PermanentError) Is(target error) bool {
+	return target == ErrPermanent
+}
+
+// ErrUnavailable matches every UnavailableError.
+var ErrUnavailable = errors.New("engine unavailable")
+
+// UnavailableError reports a failure of the engine backend itself, such as
+// an unreachable host or a crashed engine, as opposed to a bad request.
+// RetryAfter is set when the backend is known to stay unavailable for a
+// while.
+type UnavailableError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return "engine unavailable: " + e.Err.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
@@ -2,6 +2,7 @@ package ports
 
 import (
 	"context"
+	"time"
 )
 
 type AnalyzeRequest struct {
@@ -9,23 +10,150 @@ type AnalyzeRequest struct {
 	PGN      string
 	UCIMoves string
 	SANMoves string
+	Limits   SearchLimits
+	MultiPV  int
+	Profile  string
+	Options  map[string]string
+	// Priority is one of the Priority* classes; empty means normal.
+	Priority string
+	// Plies, when set, selects which positions of the game are analyzed
+	// instead of the final one.
+	Plies *PlyRange
+	// TimeBudget, when positive, caps the total time of a multi-ply
+	// analysis below the server's own limit; the plies share it.
+	TimeBudget time.Duration
+
+	// Progress, when set, receives every scored info line while the search
+	// runs. It is called from the engine's goroutine and must not block.
+	Progress func(AnalysisUpdate)
+}
+
+// Scheduling classes, most urgent first.
+const (
+	PriorityInteractive = "interactive"
+	PriorityNormal      = "normal"
+	PriorityBatch       = "batch"
+)
+
+// AnalysisUpdate is an intermediate search result. Scores are from White's
+// perspective.
+type AnalysisUpdate struct {
+	Depth          int      `json:"depth"`
+	SelDepth       int      `json:"seldepth,omitempty"`
+	MultiPV        int      `json:"multipv"`
+	EvaluationCp   *int     `json:"evaluationCp,omitempty"`
+	EvaluationMate *int     `json:"evaluationMate,omitempty"`
+	EvalBar        *int     `json:"evalBar,omitempty"`
+	Nodes          int      `json:"nodes,omitempty"`
+	NPS            int      `json:"nps,omitempty"`
+	PVUCI          []string `json:"pvUci"`
+	PVSAN          []string `json:"pvSan,omitempty"`
+	// Ply is set when several positions of a game are analyzed.
+	Ply *int `json:"ply,omitempty"`
+}
+
+// PlyRange selects positions of a game by ply: 0 is the starting position
+// and n the position after the nth move. A negative To means the last ply.
+type PlyRange struct {
+	From int
+	To   int
+}
+
+// SearchLimits mirrors the UCI "go" parameters. Zero means unset; times
+// are in milliseconds.
+type SearchLimits struct {
+	Depth     int
+	MoveTime  int
+	Nodes     int
+	Mate      int
+	WTime     int
+	BTime     int
+	WInc      int
+	BInc      int
+	MovesToGo int
 }
 
 type AnalyzeResult struct {
-	BestMoveUCI    string `json:"bestMoveUci"`
-	BestMoveSAN    string `json:"bestMoveSan,omitempty"`
-	EvaluationCp   *int   `json:"evaluationCp,omitempty"`
-	EvaluationMate *int   `json:"evaluationMate,omitempty"`
-	EvalBar        *int   `json:"evalBar,omitempty"`
-	Depth          int    `json:"depth,omitempty"`
-	Nodes          int    `json:"nodes,omitempty"`
-	NPS            int    `json:"nps,omitempty"`
-	PV             string `json:"pv,omitempty"`
-	PositionFEN    string `json:"positionFen,omitempty"`
-	Raw            string `json:"raw,omitempty"`
+	BestMoveUCI    string            `json:"bestMoveUci"`
+	BestMoveSAN    string            `json:"bestMoveSan,omitempty"`
+	EvaluationCp   *int              `json:"evaluationCp,omitempty"`
+	EvaluationMate *int              `json:"evaluationMate,omitempty"`
+	EvalBar        *int              `json:"evalBar,omitempty"`
+	Depth          int               `json:"depth,omitempty"`
+	Nodes          int               `json:"nodes,omitempty"`
+	NPS            int               `json:"nps,omitempty"`
+	PV             string            `json:"pv,omitempty"`
+	PositionFEN    string            `json:"positionFen,omitempty"`
+	Lines          []PVLine          `json:"lines,omitempty"`
+	Options        map[string]string `json:"options,omitempty"`
+	Raw            string            `json:"raw,omitempty"`
+	Cache          *CacheInfo        `json:"cache,omitempty"`
+	Draw           *DrawStatus       `json:"draw,omitempty"`
+	// Plies holds one result per selected position when a range of plies
+	// was requested; the top-level fields then describe the last of them.
+	Plies []PlyResult `json:"plies,omitempty"`
+}
+
+// PlyResult is the analysis of one position of a game, with the move that
+// led to it (none for the starting position).
+type PlyResult struct {
+	Ply     int    `json:"ply"`
+	MoveUCI string `json:"moveUci,omitempty"`
+	MoveSAN string `json:"moveSan,omitempty"`
+	AnalyzeResult
+}
+
+// DrawStatus is what the game history says about draws in the analyzed
+// position. Repetitions counts its occurrences, this one included; moves
+// before a supplied FEN are unknown and not counted.
+type DrawStatus struct {
+	Repetitions         int  `json:"repetitions"`
+	ThreefoldRepetition bool `json:"threefoldRepetition"`
+	HalfmoveClock       int  `json:"halfmoveClock"`
+	FiftyMoveRule       bool `json:"fiftyMoveRule"`
+}
+
+// CacheInfo tells whether a result was served from the analysis cache and,
+// for hits, which stored search answered it.
+type CacheInfo struct {
+	Hit      bool       `json:"hit"`
+	Depth    int        `json:"depth,omitempty"`
+	StoredAt *time.Time `json:"storedAt,omitempty"`
+}
+
+// PVLine is one candidate line, best first. Scores are from White's
+// perspective like the top-level evaluation.
+type PVLine struct {
+	Rank           int      `json:"rank"`
+	EvaluationCp   *int     `json:"evaluationCp,omitempty"`
+	EvaluationMate *int     `json:"evaluationMate,omitempty"`
+	EvalBar        *int     `json:"evalBar,omitempty"`
+	Depth          int      `json:"depth,omitempty"`
+	MovesUCI       []string `json:"movesUci"`
+	MovesSAN       []string `json:"movesSan,omitempty"`
 }
 
 type StockfishEnginePort interface {
 	Health(ctx context.Context) error
 	Analyze(ctx context.Context, req AnalyzeRequest) (AnalyzeResult, error)
 }
+
+// EngineSession is a long-running "go infinite" search on a leased engine.
+type EngineSession interface {
+	// Snapshot returns the best lines found so far for the current position.
+	Snapshot() AnalyzeResult
+	// SetPosition restarts the search on a new position using the same engine.
+	SetPosition(ctx context.Context, req AnalyzeRequest) error
+	// Close stops the search and returns the engine to its pool.
+	Close() error
+}
+
+type SessionEnginePort interface {
+	StartSession(ctx context.Context, req AnalyzeRequest) (EngineSession, error)
+}
+
+// EngineBackend serves both one-off searches and infinite sessions.
+type EngineBackend interface {
+	StockfishEnginePort
+	SessionEnginePort
+}
@@ -0,0 +1,108 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// Breaker opens after threshold consecutive failures and rejects calls for
+// openTimeout. It then lets a single probe through: success closes it,
+// failure opens it again.
+type Breaker struct {
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	opens    int
+	rejected int
+}
+
+func NewBreaker(threshold int, openTimeout time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{threshold: threshold, openTimeout: openTimeout, state: StateClosed}
+}
+
+// Allow reports whether a call may proceed. A rejected call gets an
+// UnavailableError carrying the time until the next probe.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if wait := b.openTimeout - time.Since(b.openedAt); wait > 0 {
+			b.rejected++
+			return &ports.UnavailableError{Err: errCircuitOpen, RetryAfter: wait}
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			b.rejected++
+			return &ports.UnavailableError{Err: errCircuitOpen, RetryAfter: time.Second}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	b.state = StateClosed
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		if b.state != StateOpen {
+			b.opens++
+		}
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Abandon ends a call that says nothing about the backend, such as one the
+// caller canceled, so that another probe may run.
+func (b *Breaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// BreakerStats is a snapshot of the breaker for /health and /metrics.
+type BreakerStats struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	Opens               int    `json:"opens"`
+	Rejected            int    `json:"rejected"`
+}
+
+func (b *Breaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerStats{State: b.state, ConsecutiveFailures: b.failures, Opens: b.opens, Rejected: b.rejected}
+}
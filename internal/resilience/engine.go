@@ -0,0 +1,120 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// Engine retries searches that failed because of the backend, with
+// exponential backoff and jitter, and stops calling a backend that keeps
+// failing until its breaker lets a probe through.
+type Engine struct {
+	next       ports.EngineBackend
+	breaker    *Breaker
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	retries    atomic.Int64
+}
+
+func New(next ports.EngineBackend, breaker *Breaker, maxRetries int, backoff, maxBackoff time.Duration) *Engine {
+	return &Engine{next: next, breaker: breaker, maxRetries: maxRetries, backoff: backoff, maxBackoff: maxBackoff}
+}
+
+func (e *Engine) Health(ctx context.Context) error {
+	if err := e.breaker.Allow(); err != nil {
+		return err
+	}
+	err := e.next.Health(ctx)
+	e.record(ctx, err)
+	return err
+}
+
+func (e *Engine) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	for attempt := 0; ; attempt++ {
+		if err := e.breaker.Allow(); err != nil {
+			return ports.AnalyzeResult{}, err
+		}
+		result, err := e.next.Analyze(ctx, req)
+		e.record(ctx, err)
+		if err == nil || !Retryable(err) || attempt >= e.maxRetries {
+			return result, err
+		}
+		e.retries.Add(1)
+		if err := sleep(ctx, e.delay(attempt)); err != nil {
+			return ports.AnalyzeResult{}, err
+		}
+	}
+}
+
+// StartSession is not retried: a session that fails to start is reported
+// to the client, who may simply start another.
+func (e *Engine) StartSession(ctx context.Context, req ports.AnalyzeRequest) (ports.EngineSession, error) {
+	if err := e.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	s, err := e.next.StartSession(ctx, req)
+	e.record(ctx, err)
+	return s, err
+}
+
+// Stats reports the breaker state and the number of retried searches.
+type Stats struct {
+	Breaker BreakerStats `json:"breaker"`
+	Retries int64        `json:"retries"`
+}
+
+func (e *Engine) Stats() Stats {
+	return Stats{Breaker: e.breaker.Stats(), Retries: e.retries.Load()}
+}
+
+// record feeds the outcome of a call to the breaker. Only backend failures
+// count against it; bad requests prove the backend answered.
+func (e *Engine) record(ctx context.Context, err error) {
+	switch {
+	case err == nil:
+		e.breaker.Success()
+	case errors.Is(err, ports.ErrUnavailable):
+		e.breaker.Failure()
+	case ctx.Err() != nil, errors.Is(err, ports.ErrOverloaded):
+		e.breaker.Abandon()
+	default:
+		e.breaker.Success()
+	}
+}
+
+// Retryable reports whether err is a backend failure worth another try.
+// Rejections by an open breaker are not: retrying cannot succeed sooner.
+// Neither are permanent failures such as a changed host key.
+func Retryable(err error) bool {
+	return errors.Is(err, ports.ErrUnavailable) && !errors.Is(err, errCircuitOpen) && !errors.Is(err, ports.ErrPermanent)
+}
+
+// delay returns the backoff before retry attempt+1: exponential growth
+// capped at maxBackoff, with full jitter.
+func (e *Engine) delay(attempt int) time.Duration {
+	d := e.backoff << attempt
+	if d <= 0 || (e.maxBackoff > 0 && d > e.maxBackoff) {
+		d = e.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,122 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aminammar1/stockfish-go-ec2/internal/ports"
+)
+
+// flakyBackend fails its first failures searches with err.
+type flakyBackend struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (b *flakyBackend) Health(ctx context.Context) error { return nil }
+
+func (b *flakyBackend) Analyze(ctx context.Context, req ports.AnalyzeRequest) (ports.AnalyzeResult, error) {
+	b.calls++
+	if b.calls <= b.failures {
+		return ports.AnalyzeResult{}, b.err
+	}
+	return ports.AnalyzeResult{BestMoveUCI: "e2e4"}, nil
+}
+
+func (b *flakyBackend) StartSession(ctx context.Context, req ports.AnalyzeRequest) (ports.EngineSession, error) {
+	return nil, errors.New("not supported")
+}
+
+var errDial = &ports.UnavailableError{Err: errors.New("dial tcp: connection refused")}
+
+func TestEngine_RetriesBackendFailures(t *testing.T) {
+	backend := &flakyBackend{failures: 2, err: errDial}
+	e := New(backend, NewBreaker(5, time.Minute), 2, time.Millisecond, 5*time.Millisecond)
+
+	res, err := e.Analyze(context.Background(), ports.AnalyzeRequest{})
+	if err != nil || res.BestMoveUCI != "e2e4" {
+		t.Fatalf("Analyze = %+v, %v", res, err)
+	}
+	if backend.calls != 3 || e.Stats().Retries != 2 {
+		t.Errorf("calls = %d, retries = %d", backend.calls, e.Stats().Retries)
+	}
+	if e.Stats().Breaker.State != StateClosed {
+		t.Errorf("breaker %s after a success", e.Stats().Breaker.State)
+	}
+}
+
+func TestEngine_DoesNotRetryBadRequests(t *testing.T) {
+	backend := &flakyBackend{failures: 1, err: errors.New("invalid fen")}
+	e := New(backend, NewBreaker(1, time.Minute), 3, time.Millisecond, time.Millisecond)
+
+	if _, err := e.Analyze(context.Background(), ports.AnalyzeRequest{}); err == nil {
+		t.Fatal("expected the request error")
+	}
+	if backend.calls != 1 {
+		t.Errorf("bad request was tried %d times", backend.calls)
+	}
+	if e.Stats().Breaker.State != StateClosed {
+		t.Error("a bad request must not open the breaker")
+	}
+}
+
+func TestEngine_BreakerOpensAndProbes(t *testing.T) {
+	backend := &flakyBackend{failures: 2, err: errDial}
+	e := New(backend, NewBreaker(2, 20*time.Millisecond), 0, 0, 0)
+
+	e.Analyze(context.Background(), ports.AnalyzeRequest{})
+	e.Analyze(context.Background(), ports.AnalyzeRequest{})
+	if e.Stats().Breaker.State != StateOpen {
+		t.Fatalf("breaker %s after 2 failures", e.Stats().Breaker.State)
+	}
+
+	_, err := e.Analyze(context.Background(), ports.AnalyzeRequest{})
+	var unavailable *ports.UnavailableError
+	if !errors.As(err, &unavailable) || unavailable.RetryAfter <= 0 {
+		t.Fatalf("expected a fast rejection with Retry-After, got %v", err)
+	}
+	if backend.calls != 2 {
+		t.Errorf("open breaker let a call through")
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if _, err := e.Analyze(context.Background(), ports.AnalyzeRequest{}); err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	stats := e.Stats().Breaker
+	if stats.State != StateClosed || stats.Opens != 1 || stats.Rejected != 1 {
+		t.Errorf("unexpected breaker stats %+v", stats)
+	}
+}
+
+func TestBreaker_HalfOpenFailureReopens(t *testing.T) {
+	b := NewBreaker(1, 10*time.Millisecond)
+	b.Failure()
+	time.Sleep(15 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+	if err := b.Allow(); err == nil {
+		t.Fatal("only one probe may run while half-open")
+	}
+	b.Failure()
+	if b.Stats().State != StateOpen {
+		t.Errorf("state %s after a failed probe", b.Stats().State)
+	}
+}
+
+func TestEngine_DoesNotRetryPermanentFailures(t *testing.T) {
+	err := &ports.UnavailableError{Err: &ports.PermanentError{Err: errors.New("engine binary not found")}}
+	backend := &flakyBackend{failures: 1, err: err}
+	e := New(backend, NewBreaker(5, time.Minute), 3, time.Millisecond, time.Millisecond)
+
+	if _, err := e.Analyze(context.Background(), ports.AnalyzeRequest{}); !errors.Is(err, ports.ErrPermanent) {
+		t.Fatalf("err = %v, want a permanent failure", err)
+	}
+	if backend.calls != 1 {
+		t.Errorf("permanent failure was tried %d times", backend.calls)
+	}
+}
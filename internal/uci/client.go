@@ -0,0 +1,321 @@
+package uci
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrExited        = errors.New("uci: engine exited")
+	ErrSearchRunning = errors.New("uci: search already running")
+	ErrMultiline     = errors.New("uci: command spans several lines")
+)
+
+// Client speaks UCI to one engine. A single goroutine reads the engine's
+// output line by line and routes each parsed line to whoever waits for it;
+// commands may be sent from any goroutine.
+type Client struct {
+	w   io.Writer
+	wmu sync.Mutex
+
+	// KeepRaw makes searches record every output line for Search.Raw.
+	KeepRaw bool
+
+	mu      sync.Mutex
+	id      ID
+	options []Option
+	uciok   chan struct{}
+	readyok chan struct{}
+	search  *Search
+	done    chan struct{}
+	err     error
+}
+
+func NewClient(w io.Writer, r io.Reader) *Client {
+	c := &Client{
+		w:       w,
+		uciok:   make(chan struct{}, 1),
+		readyok: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go c.read(r)
+	return c
+}
+
+func (c *Client) read(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		c.handle(scanner.Text())
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = ErrExited
+	}
+	c.mu.Lock()
+	c.err = err
+	s := c.search
+	c.search = nil
+	c.mu.Unlock()
+	if s != nil {
+		s.finish(BestMove{}, err)
+	}
+	close(c.done)
+}
+
+func (c *Client) handle(line string) {
+	line = strings.TrimSpace(line)
+	keyword, _, _ := strings.Cut(line, " ")
+
+	c.mu.Lock()
+	s := c.search
+	switch keyword {
+	case "id":
+		parseID(line, &c.id)
+	case "option":
+		c.options = append(c.options, ParseOption(line))
+	case "bestmove":
+		c.search = nil
+	}
+	c.mu.Unlock()
+
+	if s != nil && s.keepRaw {
+		s.record(line)
+	}
+
+	switch keyword {
+	case "uciok":
+		signal(c.uciok)
+	case "readyok":
+		signal(c.readyok)
+	case "info":
+		if s != nil {
+			s.add(ParseInfo(line))
+		}
+	case "bestmove":
+		if s != nil {
+			s.finish(ParseBestMove(line), nil)
+		}
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Send writes commands to the engine, one per line. A command containing a
+// line break is refused before anything is written, since the engine would
+// read the rest as further commands.
+func (c *Client) Send(cmds ...string) error {
+	for _, cmd := range cmds {
+		if strings.ContainsAny(cmd, "\r\n") {
+			return ErrMultiline
+		}
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	for _, cmd := range cmds {
+		if _, err := fmt.Fprintln(c.w, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) wait(ctx context.Context, ch chan struct{}) error {
+	select {
+	case <-ch:
+		return nil
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Handshake sends "uci" and waits for "uciok". ID and Options are filled
+// from the lines in between.
+func (c *Client) Handshake(ctx context.Context) error {
+	c.mu.Lock()
+	c.id = ID{}
+	c.options = nil
+	c.mu.Unlock()
+	drain(c.uciok)
+	if err := c.Send("uci"); err != nil {
+		return err
+	}
+	return c.wait(ctx, c.uciok)
+}
+
+// IsReady sends "isready" and waits for "readyok".
+func (c *Client) IsReady(ctx context.Context) error {
+	drain(c.readyok)
+	if err := c.Send("isready"); err != nil {
+		return err
+	}
+	return c.wait(ctx, c.readyok)
+}
+
+func drain(ch chan struct{}) {
+	select {
+	case <-ch:
+	default:
+	}
+}
+
+func (c *Client) ID() ID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.id
+}
+
+func (c *Client) Options() []Option {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Option(nil), c.options...)
+}
+
+// Err returns why the engine output ended, or nil while it is running.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Done is closed once the engine's output ends.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Go starts a search with the given "go" arguments, e.g. "depth 20".
+func (c *Client) Go(args string) (*Search, error) {
+	s := &Search{client: c, keepRaw: c.KeepRaw, info: make(chan Info, 128), done: make(chan struct{}), latest: map[int]Info{}}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	if c.search != nil {
+		c.mu.Unlock()
+		return nil, ErrSearchRunning
+	}
+	c.search = s
+	c.mu.Unlock()
+
+	cmd := "go"
+	if args != "" {
+		cmd += " " + args
+	}
+	if err := c.Send(cmd); err != nil {
+		c.mu.Lock()
+		c.search = nil
+		c.mu.Unlock()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Search is one running "go" command.
+type Search struct {
+	client  *Client
+	keepRaw bool
+	info    chan Info
+	done    chan struct{}
+
+	mu     sync.Mutex
+	latest map[int]Info
+	raw    []string
+	best   BestMove
+	err    error
+}
+
+// Info streams parsed info lines while the search runs and is closed when
+// it ends. Updates are dropped rather than blocking the engine when the
+// receiver falls behind; Latest always has the newest line per PV.
+func (s *Search) Info() <-chan Info {
+	return s.info
+}
+
+// Done is closed when bestmove arrives or the engine exits.
+func (s *Search) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *Search) add(info Info) {
+	if info.Score != nil && len(info.PV) > 0 {
+		s.mu.Lock()
+		prev, ok := s.latest[info.MultiPV]
+		// Keep exact scores over bounds from aspiration window fail highs/lows.
+		bound := info.Score.LowerBound || info.Score.UpperBound
+		if !ok || !bound || prev.Depth < info.Depth {
+			s.latest[info.MultiPV] = info
+		}
+		s.mu.Unlock()
+	}
+	select {
+	case s.info <- info:
+	default:
+	}
+}
+
+func (s *Search) record(line string) {
+	s.mu.Lock()
+	s.raw = append(s.raw, line)
+	s.mu.Unlock()
+}
+
+func (s *Search) finish(best BestMove, err error) {
+	s.mu.Lock()
+	s.best = best
+	s.err = err
+	s.mu.Unlock()
+	close(s.info)
+	close(s.done)
+}
+
+// Stop asks the engine to end the search; bestmove still follows.
+func (s *Search) Stop() error {
+	return s.client.Send("stop")
+}
+
+// Wait blocks until bestmove arrives, the engine exits or ctx is done.
+func (s *Search) Wait(ctx context.Context) (BestMove, error) {
+	select {
+	case <-s.done:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.best, s.err
+	case <-ctx.Done():
+		return BestMove{}, ctx.Err()
+	}
+}
+
+// Latest returns the newest scored line for each PV, ordered by multipv.
+func (s *Search) Latest() []Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Info, 0, len(s.latest))
+	for _, info := range s.latest {
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].MultiPV < out[j].MultiPV })
+	return out
+}
+
+// Raw returns the output lines seen during the search when KeepRaw is set.
+func (s *Search) Raw() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.raw...)
+}
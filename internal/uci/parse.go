@@ -0,0 +1,199 @@
+package uci
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ID is the engine identification sent before uciok.
+type ID struct {
+	Name   string
+	Author string
+}
+
+// Option is one "option name ... type ..." line from the uci handshake.
+type Option struct {
+	Name    string
+	Type    string
+	Default string
+	Min     *int
+	Max     *int
+	Vars    []string
+}
+
+// Score is the evaluation from the side to move's perspective.
+type Score struct {
+	Cp         *int
+	Mate       *int
+	LowerBound bool
+	UpperBound bool
+}
+
+type Info struct {
+	Depth    int
+	SelDepth int
+	MultiPV  int
+	Score    *Score
+	Nodes    int
+	NPS      int
+	Time     int
+	HashFull int
+	TBHits   int
+	CurrMove string
+	PV       []string
+	String   string
+}
+
+type BestMove struct {
+	Move   string
+	Ponder string
+}
+
+// ParseInfo parses an "info ..." line. Unknown tokens are skipped.
+func ParseInfo(line string) Info {
+	info := Info{MultiPV: 1}
+	fields := strings.Fields(line)
+	atoi := func(i int) int {
+		if i < len(fields) {
+			if v, err := strconv.Atoi(fields[i]); err == nil {
+				return v
+			}
+		}
+		return 0
+	}
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "depth":
+			info.Depth = atoi(i + 1)
+			i++
+		case "seldepth":
+			info.SelDepth = atoi(i + 1)
+			i++
+		case "multipv":
+			info.MultiPV = atoi(i + 1)
+			i++
+		case "nodes":
+			info.Nodes = atoi(i + 1)
+			i++
+		case "nps":
+			info.NPS = atoi(i + 1)
+			i++
+		case "time":
+			info.Time = atoi(i + 1)
+			i++
+		case "hashfull":
+			info.HashFull = atoi(i + 1)
+			i++
+		case "tbhits":
+			info.TBHits = atoi(i + 1)
+			i++
+		case "currmove":
+			if i+1 < len(fields) {
+				info.CurrMove = fields[i+1]
+			}
+			i++
+		case "score":
+			if i+2 >= len(fields) {
+				continue
+			}
+			v, err := strconv.Atoi(fields[i+2])
+			if err != nil {
+				continue
+			}
+			score := &Score{}
+			switch fields[i+1] {
+			case "cp":
+				score.Cp = &v
+			case "mate":
+				score.Mate = &v
+			default:
+				continue
+			}
+			i += 2
+			if i+1 < len(fields) {
+				switch fields[i+1] {
+				case "lowerbound":
+					score.LowerBound = true
+					i++
+				case "upperbound":
+					score.UpperBound = true
+					i++
+				}
+			}
+			info.Score = score
+		case "pv":
+			info.PV = append([]string(nil), fields[i+1:]...)
+			return info
+		case "string":
+			info.String = strings.Join(fields[i+1:], " ")
+			return info
+		}
+	}
+	return info
+}
+
+// ParseBestMove parses a "bestmove <move> [ponder <move>]" line.
+func ParseBestMove(line string) BestMove {
+	fields := strings.Fields(line)
+	var bm BestMove
+	if len(fields) >= 2 {
+		bm.Move = fields[1]
+	}
+	if len(fields) >= 4 && fields[2] == "ponder" {
+		bm.Ponder = fields[3]
+	}
+	return bm
+}
+
+// ParseOption parses an "option name <name> type <type> ..." line. Option
+// names may contain spaces, so values run until the next keyword.
+func ParseOption(line string) Option {
+	var opt Option
+	fields := strings.Fields(line)
+	keywords := map[string]bool{"name": true, "type": true, "default": true, "min": true, "max": true, "var": true}
+
+	for i := 1; i < len(fields); {
+		key := fields[i]
+		j := i + 1
+		for j < len(fields) && !keywords[fields[j]] {
+			j++
+		}
+		value := strings.Join(fields[i+1:j], " ")
+		switch key {
+		case "name":
+			opt.Name = value
+		case "type":
+			opt.Type = value
+		case "default":
+			if value != "<empty>" {
+				opt.Default = value
+			}
+		case "min":
+			if v, err := strconv.Atoi(value); err == nil {
+				opt.Min = &v
+			}
+		case "max":
+			if v, err := strconv.Atoi(value); err == nil {
+				opt.Max = &v
+			}
+		case "var":
+			opt.Vars = append(opt.Vars, value)
+		}
+		i = j
+	}
+	return opt
+}
+
+func parseID(line string, id *ID) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return
+	}
+	value := strings.Join(fields[2:], " ")
+	switch fields[1] {
+	case "name":
+		id.Name = value
+	case "author":
+		id.Author = value
+	}
+}
@@ -0,0 +1,142 @@
+package uci
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInfo(t *testing.T) {
+	info := ParseInfo("info depth 20 seldepth 28 multipv 2 score cp -35 upperbound nodes 123456 nps 987654 hashfull 12 tbhits 0 time 125 pv e7e5 g1f3 b8c6")
+	if info.Depth != 20 || info.SelDepth != 28 || info.MultiPV != 2 {
+		t.Errorf("depth/seldepth/multipv = %d/%d/%d", info.Depth, info.SelDepth, info.MultiPV)
+	}
+	if info.Score == nil || info.Score.Cp == nil || *info.Score.Cp != -35 || !info.Score.UpperBound {
+		t.Errorf("score = %+v", info.Score)
+	}
+	if info.Nodes != 123456 || info.NPS != 987654 || info.Time != 125 || info.HashFull != 12 {
+		t.Errorf("counters = %+v", info)
+	}
+	if strings.Join(info.PV, " ") != "e7e5 g1f3 b8c6" {
+		t.Errorf("pv = %v", info.PV)
+	}
+
+	mate := ParseInfo("info depth 5 score mate -3 pv h7h8")
+	if mate.Score == nil || mate.Score.Mate == nil || *mate.Score.Mate != -3 || mate.MultiPV != 1 {
+		t.Errorf("mate info = %+v", mate)
+	}
+}
+
+func TestParseOption(t *testing.T) {
+	opt := ParseOption("option name Skill Level type spin default 20 min 0 max 20")
+	if opt.Name != "Skill Level" || opt.Type != "spin" || opt.Default != "20" {
+		t.Errorf("option = %+v", opt)
+	}
+	if opt.Min == nil || *opt.Min != 0 || opt.Max == nil || *opt.Max != 20 {
+		t.Errorf("bounds = %v %v", opt.Min, opt.Max)
+	}
+
+	combo := ParseOption("option name Analysis Contempt type combo default Both var Off var White var Black var Both")
+	if combo.Name != "Analysis Contempt" || len(combo.Vars) != 4 {
+		t.Errorf("combo = %+v", combo)
+	}
+}
+
+func TestParseBestMove(t *testing.T) {
+	bm := ParseBestMove("bestmove e2e4 ponder e7e5")
+	if bm.Move != "e2e4" || bm.Ponder != "e7e5" {
+		t.Errorf("bestmove = %+v", bm)
+	}
+}
+
+// scriptedEngine answers the handful of commands the client sends.
+func scriptedEngine(t *testing.T, in io.Reader, out io.WriteCloser) {
+	t.Helper()
+	go func() {
+		defer out.Close()
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			switch cmd := scanner.Text(); {
+			case cmd == "uci":
+				io.WriteString(out, "id name Scripted\nid author Test\noption name Hash type spin default 16 min 1 max 1024\nuciok\n")
+			case cmd == "isready":
+				io.WriteString(out, "readyok\n")
+			case strings.HasPrefix(cmd, "go"):
+				io.WriteString(out, "info depth 1 score cp 10 pv e2e4\ninfo depth 2 score cp 20 pv d2d4 d7d5\nbestmove d2d4 ponder d7d5\n")
+			case cmd == "quit":
+				return
+			}
+		}
+	}()
+}
+
+func TestClientSearch(t *testing.T) {
+	engineIn, clientOut := io.Pipe()
+	clientIn, engineOut := io.Pipe()
+	scriptedEngine(t, engineIn, engineOut)
+
+	c := NewClient(clientOut, clientIn)
+	c.KeepRaw = true
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.Handshake(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c.ID().Name != "Scripted" || len(c.Options()) != 1 {
+		t.Errorf("id = %+v, options = %+v", c.ID(), c.Options())
+	}
+	if err := c.IsReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	search, err := c.Go("depth 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updates int
+	for range search.Info() {
+		updates++
+	}
+	best, err := search.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.Move != "d2d4" || updates != 2 {
+		t.Errorf("best = %+v, updates = %d", best, updates)
+	}
+	latest := search.Latest()
+	if len(latest) != 1 || latest[0].Depth != 2 {
+		t.Errorf("latest = %+v", latest)
+	}
+	if len(search.Raw()) != 3 {
+		t.Errorf("raw = %v", search.Raw())
+	}
+
+	c.Send("quit")
+	select {
+	case <-c.Done():
+	case <-ctx.Done():
+		t.Fatal("client did not notice engine exit")
+	}
+	if _, err := c.Go("depth 1"); err == nil {
+		t.Error("Go after exit should fail")
+	}
+}
+
+func TestClient_SendRefusesLineBreaks(t *testing.T) {
+	var out strings.Builder
+	c := NewClient(&out, strings.NewReader(""))
+	for _, cmd := range []string{"position startpos\nsetoption name Threads value 512", "go\r"} {
+		if err := c.Send("isready", cmd); !errors.Is(err, ErrMultiline) {
+			t.Errorf("%q: err = %v", cmd, err)
+		}
+	}
+	if out.Len() != 0 {
+		t.Errorf("wrote %q", out.String())
+	}
+}